@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/phaseharry/concurrent-programming-go/chapter-11/11.15/unbounded"
 )
 
 /*
@@ -61,3 +63,29 @@ func handleDirectoriesSolution2(dirs <-chan string, files chan<- string) {
 		}
 	}
 }
+
+/*
+the same idea as solution 2, with the queue of paths to push moved into an unbounded channel.
+sending to queue.In() only waits for the unbounded channel's goroutine to take the path, never for
+handleFiles, so handleDirectories can't get stuck. that goroutine feeds the queued paths to the files channel.
+*/
+func handleDirectoriesSolution3(dirs <-chan string, files chan<- string) {
+	queue := unbounded.NewChannel[string](unbounded.WithHighWatermark(1000, func(queued int) {
+		fmt.Printf("%d paths waiting to be pushed\n", queued)
+	}))
+	go func() {
+		for path := range queue.Out() {
+			files <- path
+		}
+	}()
+
+	for fullpath := range dirs {
+		fmt.Println("Reading all files from", fullpath)
+		filesInDir, _ := os.ReadDir(fullpath)
+		fmt.Printf("Pushing %d files from %s\n", len(filesInDir), fullpath)
+		for _, file := range filesInDir {
+			queue.In() <- filepath.Join(fullpath, file.Name())
+		}
+	}
+	close(queue.In())
+}
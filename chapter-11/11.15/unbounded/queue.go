@@ -0,0 +1,63 @@
+package unbounded
+
+const minQueueSize = 16
+
+/*
+a FIFO queue over a ring buffer that grows when it's full and shrinks when it's mostly empty.
+head is the index of the oldest message and count how many there are, so the messages are
+slots[head], slots[head+1], ... wrapping around to the start of the slice.
+
+unlike re-slicing like toPush = toPush[1:], popped slots are reused instead of the slice only
+ever moving forward, and they're set back to the zero value so the queue doesn't keep them alive.
+*/
+type queue[T any] struct {
+	slots []T
+	head  int
+	count int
+}
+
+func newQueue[T any]() queue[T] {
+	return queue[T]{slots: make([]T, minQueueSize)}
+}
+
+func (q *queue[T]) len() int {
+	return q.count
+}
+
+func (q *queue[T]) push(message T) {
+	if q.count == len(q.slots) {
+		q.resize(len(q.slots) * 2)
+	}
+	q.slots[(q.head+q.count)%len(q.slots)] = message
+	q.count++
+}
+
+// must only be called when the queue isn't empty
+func (q *queue[T]) front() T {
+	return q.slots[q.head]
+}
+
+// must only be called when the queue isn't empty
+func (q *queue[T]) pop() T {
+	var zero T
+	v := q.slots[q.head]
+	q.slots[q.head] = zero
+	q.head = (q.head + 1) % len(q.slots)
+	q.count--
+
+	// gives the memory of a burst back once it's been received
+	if len(q.slots) > minQueueSize && q.count <= len(q.slots)/4 {
+		q.resize(len(q.slots) / 2)
+	}
+	return v
+}
+
+// copies the messages into a new slice of the given size, starting at index 0
+func (q *queue[T]) resize(size int) {
+	slots := make([]T, size)
+	for i := range q.count {
+		slots[i] = q.slots[(q.head+i)%len(q.slots)]
+	}
+	q.slots = slots
+	q.head = 0
+}
@@ -0,0 +1,121 @@
+package unbounded
+
+/*
+Channel is a channel whose senders never wait for a receiver. messages sent to In() are queued in
+memory until they can be received from Out(), however many there are. In() itself is unbuffered, so a
+send still waits for the goroutine moving the messages to take it, which takes longer while that
+goroutine is busy, for instance running the WithHighWatermark callback. this is the toPush slice from
+handleDirectoriesSolution2 (see solutions.go) made reusable, with In() and Out() instead of the two
+sides of a select.
+
+a goroutine started by NewChannel moves the messages between the two:
+  - while the queue is empty it only waits on In(), since there's nothing to send to Out().
+  - otherwise it waits on both, receiving from In() into the back of the queue or sending the front
+    of the queue to Out(), whichever is ready first. a nil channel blocks forever in a select, so
+    setting one of them to nil turns its case off, same as in 8.11.
+  - once In() is closed, everything still queued is sent to Out() and then Out() is closed, so a
+    consumer ranging over Out() gets every message before the loop ends.
+
+since nothing stops the queue from growing, WithHighWatermark can be used to find out when it gets big.
+*/
+type Channel[T any] struct {
+	in    chan T
+	out   chan T
+	queue queue[T]
+
+	highWatermark   int
+	onHighWatermark func(queued int)
+	aboveWatermark  bool
+}
+
+// settings given to NewChannel, kept out of Channel so options don't need the message type
+type config struct {
+	highWatermark   int
+	onHighWatermark func(queued int)
+}
+
+type Option func(*config)
+
+/*
+WithHighWatermark calls fn with the number of queued messages whenever the queue grows to n messages.
+it's only called again once the queue went back below n, so a queue that stays big doesn't call it
+for every message. fn is called by the goroutine moving the messages, so it must not block or send to In().
+panics if n is less than 1, a queue is never below 0 messages so the callback would never be re-armed.
+*/
+func WithHighWatermark(n int, fn func(queued int)) Option {
+	if n < 1 {
+		panic("unbounded: high watermark must be at least 1")
+	}
+	return func(c *config) {
+		c.highWatermark = n
+		c.onHighWatermark = fn
+	}
+}
+
+func NewChannel[T any](opts ...Option) *Channel[T] {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	c := &Channel[T]{
+		in:              make(chan T),
+		out:             make(chan T),
+		queue:           newQueue[T](),
+		highWatermark:   cfg.highWatermark,
+		onHighWatermark: cfg.onHighWatermark,
+	}
+	go c.run()
+	return c
+}
+
+// In returns the channel to send messages to. closing it closes Out() once every queued message was received.
+func (c *Channel[T]) In() chan<- T {
+	return c.in
+}
+
+// Out returns the channel to receive the messages from, in the order they were sent.
+func (c *Channel[T]) Out() <-chan T {
+	return c.out
+}
+
+func (c *Channel[T]) run() {
+	defer close(c.out)
+
+	in := c.in
+	for in != nil || c.queue.len() > 0 {
+		var out chan T
+		var next T
+		if c.queue.len() > 0 {
+			out = c.out
+			next = c.queue.front()
+		}
+
+		select {
+		case message, ok := <-in:
+			if !ok {
+				// In() was closed, only the queued messages are left to send
+				in = nil
+				continue
+			}
+			c.queue.push(message)
+			c.checkWatermark()
+		case out <- next:
+			c.queue.pop()
+			c.checkWatermark()
+		}
+	}
+}
+
+// calls onHighWatermark when the queue grew to the high watermark, and re-arms it once it's back below.
+func (c *Channel[T]) checkWatermark() {
+	if c.onHighWatermark == nil {
+		return
+	}
+	queued := c.queue.len()
+	if queued < c.highWatermark {
+		c.aboveWatermark = false
+	} else if !c.aboveWatermark {
+		c.aboveWatermark = true
+		c.onHighWatermark(queued)
+	}
+}
@@ -0,0 +1,121 @@
+package unbounded
+
+import (
+	"testing"
+	"time"
+)
+
+// receives from out, failing if nothing comes
+func receive(t *testing.T, out <-chan int) (int, bool) {
+	t.Helper()
+	select {
+	case v, ok := <-out:
+		return v, ok
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing received from Out()")
+		return 0, false
+	}
+}
+
+/*
+nobody receives while the messages are sent, so they're all queued when In() is closed. every one
+of them still has to come out, in order, before Out() is closed. enough are sent for the queue to
+grow a few times, and they're received in two halves so it shrinks and wraps around as well.
+*/
+func TestCloseFlushesQueuedMessages(t *testing.T) {
+	const messages = 1000
+	c := NewChannel[int]()
+	for i := range messages / 2 {
+		c.In() <- i
+	}
+	for i := range messages / 4 {
+		if v, _ := receive(t, c.Out()); v != i {
+			t.Fatalf("expected %d, got %d", i, v)
+		}
+	}
+	for i := messages / 2; i < messages; i++ {
+		c.In() <- i
+	}
+	close(c.In())
+
+	for i := messages / 4; i < messages; i++ {
+		if v, ok := receive(t, c.Out()); !ok || v != i {
+			t.Fatalf("expected %d, got %d %v", i, v, ok)
+		}
+	}
+	if v, ok := receive(t, c.Out()); ok {
+		t.Fatalf("expected Out() to be closed, got %d", v)
+	}
+}
+
+func TestCloseWithNothingQueued(t *testing.T) {
+	c := NewChannel[int]()
+	close(c.In())
+	if _, ok := receive(t, c.Out()); ok {
+		t.Fatal("expected Out() to be closed")
+	}
+}
+
+/*
+the callback runs on the goroutine moving the messages, before it takes the next send or hands
+out the next message. so once a send or receive is done, the callback for the one before it has
+already run and anything it fired is in fired.
+*/
+func TestHighWatermarkRearms(t *testing.T) {
+	fired := make(chan int, 10)
+	c := NewChannel[int](WithHighWatermark(3, func(queued int) { fired <- queued }))
+
+	expectFired := func(want int) {
+		t.Helper()
+		select {
+		case queued := <-fired:
+			if queued != want {
+				t.Fatalf("expected the callback with %d queued, got %d", want, queued)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("the callback wasn't called")
+		}
+	}
+	expectNotFired := func() {
+		t.Helper()
+		if len(fired) != 0 {
+			t.Fatalf("the callback was called again with %d queued", <-fired)
+		}
+	}
+
+	c.In() <- 1
+	c.In() <- 2
+	c.In() <- 3
+	expectFired(3)
+
+	// it stays above the watermark, so the callback isn't called for every message
+	c.In() <- 4
+	receive(t, c.Out())
+	expectNotFired()
+
+	// back to 2 queued re-arms it, and growing to 3 again calls it again
+	receive(t, c.Out())
+	c.In() <- 5
+	expectFired(3)
+
+	close(c.In())
+	for _, want := range []int{3, 4, 5} {
+		if v, _ := receive(t, c.Out()); v != want {
+			t.Fatalf("expected %d, got %d", want, v)
+		}
+	}
+	expectNotFired()
+}
+
+func TestHighWatermarkBelowOnePanics(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("WithHighWatermark(%d) didn't panic", n)
+				}
+			}()
+			WithHighWatermark(n, func(int) {})
+		}()
+	}
+}
@@ -0,0 +1,5 @@
+//go:build !rwcheck
+
+package main
+
+const checked = false
@@ -0,0 +1,39 @@
+//go:build rwcheck
+
+package main
+
+import "testing"
+
+// run with `go test -tags rwcheck .`, the checks are compiled out otherwise
+
+func expectPanic(t *testing.T, name string, f func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("%s didn't panic", name)
+		}
+	}()
+	f()
+}
+
+func TestReadUnlockWithoutReadLockPanics(t *testing.T) {
+	rw := ReadWriteMutex{}
+	expectPanic(t, "ReadUnlock without ReadLock", rw.ReadUnlock)
+
+	rw.ReadLock()
+	rw.ReadUnlock()
+	expectPanic(t, "second ReadUnlock", rw.ReadUnlock)
+}
+
+func TestDoubleWriteUnlockPanics(t *testing.T) {
+	rw := ReadWriteMutex{}
+	expectPanic(t, "WriteUnlock without WriteLock", rw.WriteUnlock)
+
+	rw.WriteLock()
+	rw.WriteUnlock()
+	expectPanic(t, "second WriteUnlock", rw.WriteUnlock)
+
+	// the panics didn't leave anything locked
+	rw.ReadLock()
+	rw.ReadUnlock()
+}
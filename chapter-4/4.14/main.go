@@ -13,6 +13,8 @@ type ReadWriteMutex struct {
 	readersLock sync.Mutex
 
 	globalLock sync.Mutex // mutex for blocking any writers access
+
+	writerActive bool // set while the write lock is held, so the checked build (see check_enabled.go) can catch a second WriteUnlock
 }
 
 func (rw *ReadWriteMutex) ReadLock() {
@@ -37,10 +39,15 @@ func (rw *ReadWriteMutex) WriteLock() {
 		unlocked first. The goroutine that calls this will be blocked at the call
 	*/
 	rw.globalLock.Lock()
+	rw.writerActive = true
 }
 
 func (rw *ReadWriteMutex) ReadUnlock() {
 	rw.readersLock.Lock()
+	if checked && rw.readersCounter == 0 {
+		rw.readersLock.Unlock()
+		panic("ReadUnlock called without a matching ReadLock")
+	}
 	rw.readersCounter--
 
 	/*
@@ -56,5 +63,9 @@ func (rw *ReadWriteMutex) ReadUnlock() {
 }
 
 func (rw *ReadWriteMutex) WriteUnlock() {
+	if checked && !rw.writerActive {
+		panic("WriteUnlock called without a matching WriteLock")
+	}
+	rw.writerActive = false
 	rw.globalLock.Unlock()
 }
@@ -0,0 +1,6 @@
+//go:build rwcheck
+
+package main
+
+// building with `-tags rwcheck` makes ReadWriteMutex panic when it's misused instead of silently breaking
+const checked = true
@@ -31,6 +31,8 @@ type ReadWriteMutex struct {
 	readersLock sync.Mutex
 
 	globalLock sync.Mutex // mutex for blocking any writers access
+
+	writerActive bool // set while the write lock is held, so the checked build (see check_enabled.go) can catch a second WriteUnlock
 }
 
 func (rw *ReadWriteMutex) ReadLock() {
@@ -55,10 +57,15 @@ func (rw *ReadWriteMutex) WriteLock() {
 		unlocked first. The goroutine that calls this will be blocked at the call
 	*/
 	rw.globalLock.Lock()
+	rw.writerActive = true
 }
 
 func (rw *ReadWriteMutex) ReadUnlock() {
 	rw.readersLock.Lock()
+	if checked && rw.readersCounter == 0 {
+		rw.readersLock.Unlock()
+		panic("ReadUnlock called without a matching ReadLock")
+	}
 	rw.readersCounter--
 
 	/*
@@ -75,7 +82,11 @@ func (rw *ReadWriteMutex) ReadUnlock() {
 
 // add try write lock for exercise 2
 func (rw *ReadWriteMutex) TryWriteLock() bool {
-	return rw.globalLock.TryLock()
+	if !rw.globalLock.TryLock() {
+		return false
+	}
+	rw.writerActive = true
+	return true
 }
 
 // add try read lock for exercise 3
@@ -97,7 +108,7 @@ func (rw *ReadWriteMutex) TryReadLock() bool {
 		the same time as we read it
 	*/
 	if rw.readersCounter == 0 {
-		acquiredGlobalLock = rw.TryWriteLock()
+		acquiredGlobalLock = rw.globalLock.TryLock()
 	}
 
 	if acquiredGlobalLock {
@@ -114,5 +125,9 @@ func (rw *ReadWriteMutex) TryReadLock() bool {
 }
 
 func (rw *ReadWriteMutex) WriteUnlock() {
+	if checked && !rw.writerActive {
+		panic("WriteUnlock called without a matching WriteLock")
+	}
+	rw.writerActive = false
 	rw.globalLock.Unlock()
 }
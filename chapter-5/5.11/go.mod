@@ -1,3 +1,7 @@
 module 5.11
 
 go 1.22.2
+
+require syncutil v0.0.0
+
+replace syncutil => ../syncutil
@@ -0,0 +1,70 @@
+package rw
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+/*
+compares the readers-writer lock policies with the standard library's sync.RWMutex.
+run with `go test -bench . ./rw`, or filter them, ex. `go test -bench 'Policies/write_every_2/' ./rw`.
+
+every benchmark runs goroutines in parallel that take a read lock most of the time and a write
+lock every writeEvery iterations, holding the lock for a little bit of work so there's contention.
+*/
+
+// adapts sync.RWMutex to RWLocker
+type stdRWMutex struct {
+	sync.RWMutex
+}
+
+func (m *stdRWMutex) ReadLock()    { m.RLock() }
+func (m *stdRWMutex) ReadUnlock()  { m.RUnlock() }
+func (m *stdRWMutex) WriteLock()   { m.Lock() }
+func (m *stdRWMutex) WriteUnlock() { m.Unlock() }
+
+func BenchmarkPolicies(b *testing.B) {
+	locks := []struct {
+		name    string
+		newLock func() RWLocker
+	}{
+		{"read-preferring", func() RWLocker { return NewReadWriteMutex(WithPolicy(ReadPreferring)) }},
+		{"write-preferring", func() RWLocker { return NewReadWriteMutex(WithPolicy(WritePreferring)) }},
+		{"phase-fair", func() RWLocker { return NewReadWriteMutex(WithPolicy(PhaseFair)) }},
+		{"sync.RWMutex", func() RWLocker { return &stdRWMutex{} }},
+	}
+
+	for _, writeEvery := range []int{100, 10, 2} {
+		b.Run(fmt.Sprintf("write_every_%d", writeEvery), func(b *testing.B) {
+			for _, lock := range locks {
+				b.Run(lock.name, func(b *testing.B) {
+					benchmarkLock(b, lock.newLock(), writeEvery)
+				})
+			}
+		})
+	}
+}
+
+func benchmarkLock(b *testing.B, lock RWLocker, writeEvery int) {
+	shared := make([]int, 16)
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			if i%writeEvery == 0 {
+				lock.WriteLock()
+				for j := range shared {
+					shared[j]++
+				}
+				lock.WriteUnlock()
+			} else {
+				lock.ReadLock()
+				sum := 0
+				for _, v := range shared {
+					sum += v
+				}
+				_ = sum
+				lock.ReadUnlock()
+			}
+		}
+	})
+}
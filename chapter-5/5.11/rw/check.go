@@ -0,0 +1,85 @@
+package rw
+
+import "syncutil"
+
+/*
+misuse checks, only compiled in when building with `-tags rwcheck` (ex. `go run -tags rwcheck .`).
+
+without them a ReadUnlock() without a matching ReadLock() drives readersCounter negative and a second
+WriteUnlock() lets readers and writers in together, and the lock is silently broken from then on.
+the checked build panics straight away instead, for:
+- unlocking a read, upgradable or write lock that isn't held
+- upgrading or downgrading a lock that isn't held
+- a goroutine taking a read lock again while it already holds one and a writer is waiting. with a
+  WritePreferring or PhaseFair lock the new read lock waits for the writer, while the writer waits
+  for the read lock the goroutine already holds, so they would deadlock.
+
+the goroutines holding read locks are told apart with syncutil.GoroutineId.
+
+the checks panic while still holding the cond lock, so the ReadWriteMutex can't be used afterwards.
+that's the same as the standard library, which treats misusing a sync.RWMutex as a fatal error.
+*/
+
+/*
+records that the current goroutine holds one more read lock.
+must be called with the cond lock held.
+*/
+func (rw *ReadWriteMutex) trackRead() {
+	if !checked {
+		return
+	}
+	if rw.readHolders == nil {
+		rw.readHolders = make(map[uint64]int)
+	}
+	rw.readHolders[syncutil.GoroutineId()]++
+}
+
+/*
+checks there's a read lock to release and forgets about it. a read lock can be released by
+a different goroutine than the one that took it, so the current goroutine may not be in readHolders.
+must be called with the cond lock held.
+*/
+func (rw *ReadWriteMutex) untrackRead() {
+	if !checked {
+		return
+	}
+	if rw.readersCounter <= 0 {
+		panic("rw: ReadUnlock called without a matching ReadLock")
+	}
+	id := syncutil.GoroutineId()
+	if rw.readHolders[id] > 1 {
+		rw.readHolders[id]--
+	} else {
+		delete(rw.readHolders, id)
+	}
+}
+
+/*
+panics if the current goroutine already holds a read lock and would have to wait for a writer.
+must be called with the cond lock held.
+*/
+func (rw *ReadWriteMutex) checkRecursiveRead() {
+	if checked && rw.readHolders[syncutil.GoroutineId()] > 0 && !rw.canRead() {
+		panic("rw: recursive ReadLock while a writer is waiting would deadlock")
+	}
+}
+
+/*
+panics if the write lock isn't held. op is the name of the method being checked.
+must be called with the cond lock held.
+*/
+func (rw *ReadWriteMutex) checkWriteHeld(op string) {
+	if checked && !rw.writerActive {
+		panic("rw: " + op + " called without holding the write lock")
+	}
+}
+
+/*
+panics if the upgradable read lock isn't held. op is the name of the method being checked.
+must be called with the cond lock held.
+*/
+func (rw *ReadWriteMutex) checkUpgradableHeld(op string) {
+	if checked && !rw.upgraderActive {
+		panic("rw: " + op + " called without holding the upgradable read lock")
+	}
+}
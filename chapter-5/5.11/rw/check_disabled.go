@@ -0,0 +1,5 @@
+//go:build !rwcheck
+
+package rw
+
+const checked = false
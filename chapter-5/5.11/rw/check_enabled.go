@@ -0,0 +1,6 @@
+//go:build rwcheck
+
+package rw
+
+// building with `-tags rwcheck` turns on the misuse checks in check.go
+const checked = true
@@ -0,0 +1,84 @@
+//go:build rwcheck
+
+package rw
+
+import "testing"
+
+/*
+run with `go test -tags rwcheck ./rw`, the checks are compiled out otherwise.
+a check panics while holding the cond lock, so every case uses its own ReadWriteMutex.
+*/
+
+func expectPanic(t *testing.T, name string, f func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("%s didn't panic", name)
+		}
+	}()
+	f()
+}
+
+func TestReadUnlockWithoutReadLockPanics(t *testing.T) {
+	for _, p := range policies {
+		rw := NewReadWriteMutex(WithPolicy(p.policy))
+		rw.ReadLock()
+		rw.ReadUnlock()
+		expectPanic(t, p.name+": second ReadUnlock", rw.ReadUnlock)
+	}
+}
+
+func TestDoubleWriteUnlockPanics(t *testing.T) {
+	for _, p := range policies {
+		rw := NewReadWriteMutex(WithPolicy(p.policy))
+		rw.WriteLock()
+		rw.WriteUnlock()
+		expectPanic(t, p.name+": second WriteUnlock", rw.WriteUnlock)
+	}
+}
+
+// a read lock held by a different goroutine doesn't count, only the current goroutine's own
+func TestRecursiveReadLockWithWriterWaitingPanics(t *testing.T) {
+	for _, p := range policies {
+		if p.policy == ReadPreferring {
+			// readers never wait for a waiting writer, so a recursive read lock can't deadlock
+			continue
+		}
+		rw := NewReadWriteMutex(WithPolicy(p.policy))
+		rw.ReadLock()
+		go rw.WriteLock()
+		waitFor(t, rw, "the writer to wait", func() bool { return rw.writersWaiting == 1 })
+
+		expectPanic(t, p.name+": recursive ReadLock", rw.ReadLock)
+	}
+}
+
+func TestRecursiveReadLockWithoutWriterIsAllowed(t *testing.T) {
+	rw := NewReadWriteMutex()
+	rw.ReadLock()
+	rw.ReadLock()
+	rw.ReadUnlock()
+	rw.ReadUnlock()
+}
+
+func TestUpgradeWithoutUpgradableReadLockPanics(t *testing.T) {
+	rw := NewReadWriteMutex()
+	expectPanic(t, "Upgrade without UpgradableReadLock", rw.Upgrade)
+
+	// a plain read lock can't be upgraded either
+	rw = NewReadWriteMutex()
+	rw.ReadLock()
+	expectPanic(t, "Upgrade of a plain read lock", rw.Upgrade)
+
+	rw = NewReadWriteMutex()
+	expectPanic(t, "UpgradableReadUnlock without UpgradableReadLock", rw.UpgradableReadUnlock)
+}
+
+func TestDowngradeWithoutWriteLockPanics(t *testing.T) {
+	rw := NewReadWriteMutex()
+	expectPanic(t, "Downgrade without WriteLock", rw.Downgrade)
+
+	rw = NewReadWriteMutex()
+	rw.ReadLock()
+	expectPanic(t, "Downgrade of a read lock", rw.Downgrade)
+}
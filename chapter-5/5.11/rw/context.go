@@ -0,0 +1,116 @@
+package rw
+
+import (
+	"context"
+	"time"
+
+	"syncutil"
+)
+
+/*
+non-blocking and bounded versions of ReadLock and WriteLock.
+the waiting goroutines are woken up once ctx is done by syncutil.BroadcastOnDone.
+*/
+// TryReadLock acquires the read lock only if it's available right now and reports whether it did.
+func (rw *ReadWriteMutex) TryReadLock() bool {
+	rw.cond.L.Lock()
+	defer rw.cond.L.Unlock()
+
+	if !rw.canRead() {
+		return false
+	}
+	rw.readersCounter++
+	rw.trackRead()
+	return true
+}
+
+// TryWriteLock acquires the write lock only if it's available right now and reports whether it did.
+func (rw *ReadWriteMutex) TryWriteLock() bool {
+	rw.cond.L.Lock()
+	defer rw.cond.L.Unlock()
+
+	if !rw.canWrite() {
+		return false
+	}
+	rw.writerActive = true
+	return true
+}
+
+/*
+ReadLockContext blocks until the read lock is acquired or ctx is done.
+if ctx is done first, ctx.Err() is returned and the lock isn't held.
+*/
+func (rw *ReadWriteMutex) ReadLockContext(ctx context.Context) error {
+	rw.cond.L.Lock()
+	defer rw.cond.L.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	rw.checkRecursiveRead()
+	stop := syncutil.BroadcastOnDone(ctx, rw.cond)
+	defer stop()
+
+	if err := rw.waitToRead(ctx); err != nil {
+		return err
+	}
+	rw.readersCounter++
+	rw.trackRead()
+	return nil
+}
+
+/*
+WriteLockContext blocks until the write lock is acquired or ctx is done.
+if ctx is done first, ctx.Err() is returned and the lock isn't held.
+*/
+func (rw *ReadWriteMutex) WriteLockContext(ctx context.Context) error {
+	rw.cond.L.Lock()
+	defer rw.cond.L.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	stop := syncutil.BroadcastOnDone(ctx, rw.cond)
+	defer stop()
+
+	rw.writersWaiting++
+	for !rw.canWrite() {
+		if err := ctx.Err(); err != nil {
+			rw.abandonWrite()
+			return err
+		}
+		rw.cond.Wait()
+	}
+	rw.writersWaiting--
+	rw.writerActive = true
+	return nil
+}
+
+// ReadLockTimeout waits at most d for the read lock and reports whether it was acquired.
+func (rw *ReadWriteMutex) ReadLockTimeout(d time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return rw.ReadLockContext(ctx) == nil
+}
+
+// WriteLockTimeout waits at most d for the write lock and reports whether it was acquired.
+func (rw *ReadWriteMutex) WriteLockTimeout(d time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return rw.WriteLockContext(ctx) == nil
+}
+
+/*
+called by a writer that gives up waiting. while it was counted in writersWaiting it may have been
+blocking new readers, so they need to be woken up once it's gone. for PhaseFair, the readers
+waiting for the next write phase would wait forever if no other writer is left to have that
+phase, so the phase is ended straight away.
+must be called with the cond lock held.
+*/
+func (rw *ReadWriteMutex) abandonWrite() {
+	rw.writersWaiting--
+	if rw.policy == PhaseFair && rw.writersWaiting == 0 && !rw.writerActive {
+		rw.endWritePhase()
+	}
+	rw.cond.Broadcast()
+}
@@ -0,0 +1,148 @@
+package rw
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTryLocks(t *testing.T) {
+	for _, p := range policies {
+		rw := NewReadWriteMutex(WithPolicy(p.policy))
+
+		if !rw.TryWriteLock() {
+			t.Fatalf("%s: TryWriteLock failed on a free lock", p.name)
+		}
+		if rw.TryReadLock() || rw.TryWriteLock() {
+			t.Fatalf("%s: got in while the write lock was held", p.name)
+		}
+		rw.WriteUnlock()
+
+		if !rw.TryReadLock() || !rw.TryReadLock() {
+			t.Fatalf("%s: TryReadLock failed with only readers holding the lock", p.name)
+		}
+		if rw.TryWriteLock() {
+			t.Fatalf("%s: TryWriteLock got in while the read lock was held", p.name)
+		}
+		rw.ReadUnlock()
+		rw.ReadUnlock()
+		if !rw.TryWriteLock() {
+			t.Fatalf("%s: TryWriteLock failed once every reader left", p.name)
+		}
+		rw.WriteUnlock()
+	}
+}
+
+func TestLockContextAlreadyDone(t *testing.T) {
+	rw := NewReadWriteMutex()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// the lock is free, but a goroutine that has already given up doesn't get it
+	if err := rw.ReadLockContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ReadLockContext: expected context.Canceled, got %v", err)
+	}
+	if err := rw.WriteLockContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("WriteLockContext: expected context.Canceled, got %v", err)
+	}
+	if !rw.TryWriteLock() {
+		t.Fatal("lock left held by a cancelled call")
+	}
+}
+
+func TestReadLockContextGivesUp(t *testing.T) {
+	for _, p := range policies {
+		rw := NewReadWriteMutex(WithPolicy(p.policy))
+		rw.WriteLock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		err := rw.ReadLockContext(ctx)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("%s: expected context.DeadlineExceeded, got %v", p.name, err)
+		}
+		if rw.readersCounter != 0 || rw.readersWaiting != 0 {
+			t.Fatalf("%s: the reader that gave up is still counted", p.name)
+		}
+
+		rw.WriteUnlock()
+		if !rw.ReadLockTimeout(5 * time.Second) {
+			t.Fatalf("%s: read lock not acquired once the writer left", p.name)
+		}
+		rw.ReadUnlock()
+		if !rw.TryWriteLock() {
+			t.Fatalf("%s: lock not free after the reader left", p.name)
+		}
+	}
+}
+
+func TestWriteLockContextGivesUp(t *testing.T) {
+	for _, p := range policies {
+		rw := NewReadWriteMutex(WithPolicy(p.policy))
+		rw.ReadLock()
+
+		if rw.WriteLockTimeout(10 * time.Millisecond) {
+			t.Fatalf("%s: write lock acquired while a reader held the lock", p.name)
+		}
+		if rw.writersWaiting != 0 || rw.writerActive {
+			t.Fatalf("%s: the writer that gave up is still counted", p.name)
+		}
+
+		rw.ReadUnlock()
+		if err := rw.WriteLockContext(context.Background()); err != nil {
+			t.Fatalf("%s: %v", p.name, err)
+		}
+		rw.WriteUnlock()
+	}
+}
+
+/*
+a waiting writer blocks new readers, except with ReadPreferring. once it gives up, the reader that
+arrived after it has to be woken up and let in next to the reader still holding the lock. for
+PhaseFair the reader is waiting for a write phase that will never happen, so giving up has to
+end it.
+*/
+func TestAbandonedWriteWakesBlockedReaders(t *testing.T) {
+	for _, p := range policies {
+		if p.policy == ReadPreferring {
+			continue
+		}
+		rw := NewReadWriteMutex(WithPolicy(p.policy))
+		rw.ReadLock()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		writer := make(chan error)
+		go func() { writer <- rw.WriteLockContext(ctx) }()
+		waitFor(t, rw, "the writer to wait", func() bool { return rw.writersWaiting == 1 })
+
+		reader := make(chan struct{})
+		go func() {
+			rw.ReadLock()
+			close(reader)
+		}()
+		select {
+		case <-reader:
+			t.Fatalf("%s: reader got in ahead of a waiting writer", p.name)
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		cancel()
+		if err := <-writer; !errors.Is(err, context.Canceled) {
+			t.Fatalf("%s: expected context.Canceled, got %v", p.name, err)
+		}
+		select {
+		case <-reader:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: reader blocked by the writer wasn't woken up once it gave up", p.name)
+		}
+		if rw.writersWaiting != 0 || rw.readersCounter != 2 {
+			t.Fatalf("%s: expected no writers waiting and 2 readers, got %d and %d",
+				p.name, rw.writersWaiting, rw.readersCounter)
+		}
+		rw.ReadUnlock()
+		rw.ReadUnlock()
+		if !rw.TryWriteLock() {
+			t.Fatalf("%s: lock not free after the readers left", p.name)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package rw
+
+import "sync"
+
+/*
+method names matching the standard library's sync.RWMutex, so a ReadWriteMutex can be used anywhere
+a sync.Locker is expected (ex. Flight.Locker in chapter 12 or sync.NewCond). Lock/Unlock is the write
+side, RLock/RUnlock the read side and RLocker() returns the read side as a sync.Locker.
+*/
+
+var _ sync.Locker = (*ReadWriteMutex)(nil)
+
+// Lock acquires the write lock.
+func (rw *ReadWriteMutex) Lock() {
+	rw.WriteLock()
+}
+
+// Unlock releases the write lock.
+func (rw *ReadWriteMutex) Unlock() {
+	rw.WriteUnlock()
+}
+
+// RLock acquires a read lock.
+func (rw *ReadWriteMutex) RLock() {
+	rw.ReadLock()
+}
+
+// RUnlock releases a read lock.
+func (rw *ReadWriteMutex) RUnlock() {
+	rw.ReadUnlock()
+}
+
+// RLocker returns a sync.Locker whose Lock and Unlock acquire and release a read lock of rw.
+func (rw *ReadWriteMutex) RLocker() sync.Locker {
+	return (*rlocker)(rw)
+}
+
+type rlocker ReadWriteMutex
+
+func (r *rlocker) Lock()   { (*ReadWriteMutex)(r).ReadLock() }
+func (r *rlocker) Unlock() { (*ReadWriteMutex)(r).ReadUnlock() }
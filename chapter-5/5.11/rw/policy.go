@@ -0,0 +1,119 @@
+package rw
+
+import "context"
+
+/*
+which side of the lock gets priority when both readers and writers are waiting.
+
+  - ReadPreferring works like the ReadWriteMutex in 4.14. readers only wait for an active writer,
+    so a constant stream of readers can starve the writers forever.
+  - WritePreferring is the default and works as described in rw.go. new readers wait as long as
+    any writer is waiting, so a constant stream of writers can starve the readers forever.
+  - PhaseFair alternates between read phases and write phases. a reader that arrives while a writer
+    is active or waiting waits for the end of the next write phase, and then gets in together with every
+    other reader that was waiting, even if more writers have arrived in the meantime. a writer waits for
+    the current read phase to end, but new readers can't extend that read phase. this way a reader never
+    waits for more than one write phase and a writer never waits for more than one read phase.
+*/
+type Policy int
+
+const (
+	WritePreferring Policy = iota
+	ReadPreferring
+	PhaseFair
+)
+
+// RWLocker is the locking API of ReadWriteMutex, the same whichever policy it uses.
+type RWLocker interface {
+	ReadLock()
+	ReadUnlock()
+	WriteLock()
+	WriteUnlock()
+}
+
+// Option configures optional behaviour of a ReadWriteMutex when it's created.
+type Option func(*ReadWriteMutex)
+
+// WithPolicy chooses which side of the lock gets priority, WritePreferring if not given.
+func WithPolicy(policy Policy) Option {
+	return func(rw *ReadWriteMutex) {
+		rw.policy = policy
+	}
+}
+
+/*
+reports whether a reader that just arrived can get the read lock straight away.
+must be called with the cond lock held.
+*/
+func (rw *ReadWriteMutex) canRead() bool {
+	// new readers would keep an upgrade from ever happening
+	if rw.upgrading {
+		return false
+	}
+	if rw.policy == ReadPreferring {
+		return !rw.writerActive
+	}
+	return !rw.writerActive && rw.writersWaiting == 0
+}
+
+/*
+reports whether a writer can get the write lock. for PhaseFair the readers that
+were released by the last write phase have to get in first.
+must be called with the cond lock held.
+*/
+func (rw *ReadWriteMutex) canWrite() bool {
+	return rw.readersCounter == 0 && !rw.writerActive && rw.readersReady == 0
+}
+
+/*
+blocks a reader until it can get the read lock according to the policy, or until ctx is done.
+ctx is only checked when the goroutine has to wait, and whoever cancels it has to broadcast
+on the cond so the waiting goroutine gets to see it (see ReadLockContext).
+must be called with the cond lock held.
+*/
+func (rw *ReadWriteMutex) waitToRead(ctx context.Context) error {
+	if rw.policy == PhaseFair {
+		if !rw.canRead() {
+			return rw.waitForWritePhase(ctx)
+		}
+		return nil
+	}
+	for !rw.canRead() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rw.cond.Wait()
+	}
+	return nil
+}
+
+/*
+blocks a PhaseFair reader that can't get in straight away until the next write phase has ended.
+write phases are counted when the writer unlocks, so every reader waiting at that time is waiting
+for the same phase and they are all let in together.
+must be called with the cond lock held.
+*/
+func (rw *ReadWriteMutex) waitForWritePhase(ctx context.Context) error {
+	rw.readersWaiting++
+	target := rw.writePhase + 1
+	for rw.writePhase < target {
+		if err := ctx.Err(); err != nil {
+			// the phase hasn't ended yet, so we're still counted as waiting for it
+			rw.readersWaiting--
+			return err
+		}
+		rw.cond.Wait()
+	}
+	rw.readersReady--
+	return nil
+}
+
+/*
+ends the current write phase, letting in every PhaseFair reader that was waiting for it.
+must be called with the cond lock held.
+*/
+func (rw *ReadWriteMutex) endWritePhase() {
+	rw.readersReady += rw.readersWaiting
+	rw.readersWaiting = 0
+	rw.writePhase++
+}
@@ -1,6 +1,9 @@
 package rw
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
 /*
 The ReadWriteMutex we implemented in 4.14 is a read preferred
@@ -12,6 +15,8 @@ write-preferring readers-writer mutex in which many goroutines can
 acquire a read lock, but once a write lock gets called, additional
 reads will be blocked until all writes resolve and release the lock
 back.
+
+the priority between readers and writers can be changed with WithPolicy, see policy.go.
 */
 
 /*
@@ -19,6 +24,13 @@ readersCounter - used to track current goroutines that have the read lock
 writersWaiting - used to track current goroutines that wants to acquire the writer lock
 writerActive - bool to indicate whether or not a goroutine has a write lock or not
 cond - the condition we used to synchronize everything.
+policy - which side gets priority, WritePreferring unless another policy was chosen
+readersWaiting, readersReady, writePhase - only used by the PhaseFair policy to track the readers
+waiting for the end of a write phase, the readers let in by the last write phase and the number of
+write phases so far
+upgraderActive - whether a goroutine holds the upgradable read lock, see upgradable.go
+upgrading - whether the goroutine holding the upgradable read lock is waiting to upgrade it
+readHolders - only used by the checked build to track the read locks held by each goroutine, see check.go
 
 in this implementation,
 1. if there is a goroutine attempting to acquire a write lock and there are outstanding read locks
@@ -37,32 +49,45 @@ type ReadWriteMutex struct {
 	writersWaiting int
 	writerActive   bool
 	cond           *sync.Cond
+	policy         Policy
+	readersWaiting int
+	readersReady   int
+	writePhase     int
+	upgraderActive bool
+	upgrading      bool
+	readHolders    map[uint64]int
 }
 
-func NewReadWriteMutex() *ReadWriteMutex {
-	return &ReadWriteMutex{
+func NewReadWriteMutex(opts ...Option) *ReadWriteMutex {
+	rw := &ReadWriteMutex{
 		cond: sync.NewCond(&sync.Mutex{}),
 	}
+	for _, opt := range opts {
+		opt(rw)
+	}
+	return rw
 }
 
 func (rw *ReadWriteMutex) ReadLock() {
 	// acquire lock to make changes within the ReadWriteMutex
 	rw.cond.L.Lock()
+	rw.checkRecursiveRead()
 
 	/*
 	   if there's existing goroutines that are waiting for the write lock
 	   or there's a goroutine that's active writing, then block the goroutine
 	   that attempted the read lock and have it wait until all writes are finished.
+	   (a ReadPreferring lock only waits for the active writer and a PhaseFair
+	   lock only waits for the next write phase to end, see policy.go)
 	*/
-	for rw.writersWaiting > 0 || rw.writerActive {
-		rw.cond.Wait()
-	}
+	rw.waitToRead(context.Background())
 
 	/*
 	   once we're able to acquire the read lock once all writes are done or not writes at all, then
 	   keep track of all existing goroutines that have an outstanding read lock.
 	*/
 	rw.readersCounter++
+	rw.trackRead()
 
 	// unlock so another goroutine can attempt to get a ReadLock
 	rw.cond.L.Unlock()
@@ -80,11 +105,17 @@ func (rw *ReadWriteMutex) WriteLock() {
 	*/
 	rw.writersWaiting++
 
-	for rw.readersCounter > 0 || rw.writerActive {
+	for !rw.canWrite() {
 		rw.cond.Wait()
 	}
 
+	/*
+		the current goroutine now has the write lock. writerActive has to be set so
+		any readers and other writers are blocked until WriteUnlock() is called,
+		otherwise they could enter while we're still writing.
+	*/
 	rw.writersWaiting--
+	rw.writerActive = true
 	rw.cond.L.Unlock()
 }
 
@@ -100,9 +131,14 @@ func (rw *ReadWriteMutex) ReadUnlock() {
 	   if there are goroutines attempting to get read locks then those will be blocked until all
 	   writer locks have been released.
 	*/
+	rw.untrackRead()
 	rw.readersCounter--
 
-	if rw.readersCounter == 0 {
+	/*
+	   a goroutine upgrading its read lock is waiting to be the only reader left,
+	   so it also needs to be woken up when the second to last reader leaves.
+	*/
+	if rw.readersCounter == 0 || rw.upgrading {
 		rw.cond.Broadcast()
 	}
 
@@ -112,8 +148,13 @@ func (rw *ReadWriteMutex) ReadUnlock() {
 func (rw *ReadWriteMutex) WriteUnlock() {
 	rw.cond.L.Lock()
 
+	rw.checkWriteHeld("WriteUnlock")
+
 	// setting writer active to false & broadcasting so other write & read goroutines can get lock and process
 	rw.writerActive = false
+	if rw.policy == PhaseFair {
+		rw.endWritePhase()
+	}
 
 	rw.cond.Broadcast()
 
@@ -0,0 +1,225 @@
+package rw
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var policies = []struct {
+	name   string
+	policy Policy
+}{
+	{"WritePreferring", WritePreferring},
+	{"ReadPreferring", ReadPreferring},
+	{"PhaseFair", PhaseFair},
+}
+
+// polls the state of rw under its cond lock until cond is true
+func waitFor(t *testing.T, rw *ReadWriteMutex, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rw.cond.L.Lock()
+		ok := cond()
+		rw.cond.L.Unlock()
+		if ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+/*
+readers and writers record themselves while they hold the lock. a writer must never
+see another writer or any reader, and a reader must never see a writer.
+*/
+func TestMutualExclusion(t *testing.T) {
+	for _, p := range policies {
+		t.Run(p.name, func(t *testing.T) {
+			rw := NewReadWriteMutex(WithPolicy(p.policy))
+			var readers, writers atomic.Int32
+			shared := 0
+
+			wg := sync.WaitGroup{}
+			for g := range 8 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := range 300 {
+						if (g+i)%4 == 0 {
+							rw.WriteLock()
+							if writers.Add(1) != 1 || readers.Load() != 0 {
+								t.Error("writer doesn't have exclusive access")
+							}
+							shared++
+							writers.Add(-1)
+							rw.WriteUnlock()
+						} else {
+							rw.ReadLock()
+							readers.Add(1)
+							if writers.Load() != 0 {
+								t.Error("reader overlapped a writer")
+							}
+							_ = shared
+							readers.Add(-1)
+							rw.ReadUnlock()
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			if want := 8 * 300 / 4; shared != want {
+				t.Fatalf("expected %d writes, got %d", want, shared)
+			}
+		})
+	}
+}
+
+/*
+once a writer is waiting, a reader arriving after it has to wait until the writer is done,
+even though the lock is only held by readers.
+*/
+func TestWriterPreference(t *testing.T) {
+	rw := NewReadWriteMutex()
+	rw.ReadLock()
+
+	var order []string
+	var mutex sync.Mutex
+	record := func(s string) {
+		mutex.Lock()
+		order = append(order, s)
+		mutex.Unlock()
+	}
+
+	writerDone := make(chan struct{})
+	go func() {
+		rw.WriteLock()
+		record("writer")
+		rw.WriteUnlock()
+		close(writerDone)
+	}()
+	waitFor(t, rw, "the writer to wait", func() bool { return rw.writersWaiting == 1 })
+
+	if rw.TryReadLock() {
+		t.Fatal("a new reader got in ahead of a waiting writer")
+	}
+	readerDone := make(chan struct{})
+	go func() {
+		rw.ReadLock()
+		record("reader")
+		rw.ReadUnlock()
+		close(readerDone)
+	}()
+
+	// the first reader leaving lets the writer in, and only then the second reader
+	time.Sleep(10 * time.Millisecond)
+	rw.ReadUnlock()
+	<-writerDone
+	<-readerDone
+
+	if len(order) != 2 || order[0] != "writer" {
+		t.Fatalf("expected the writer before the new reader, got %v", order)
+	}
+}
+
+/*
+readers keep overlapping each other so the read lock is never released by all of them at once.
+a read preferring lock would let them keep it forever, the other policies must let the writer in.
+*/
+func TestNoWriterStarvation(t *testing.T) {
+	for _, p := range policies {
+		if p.policy == ReadPreferring {
+			continue
+		}
+		t.Run(p.name, func(t *testing.T) {
+			rw := NewReadWriteMutex(WithPolicy(p.policy))
+			stop := make(chan struct{})
+			wg := sync.WaitGroup{}
+			for range 4 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						select {
+						case <-stop:
+							return
+						default:
+						}
+						rw.ReadLock()
+						time.Sleep(time.Millisecond)
+						rw.ReadUnlock()
+					}
+				}()
+			}
+			waitFor(t, rw, "the readers to start", func() bool { return rw.readersCounter > 0 })
+
+			for range 10 {
+				acquired := make(chan struct{})
+				go func() {
+					rw.WriteLock()
+					rw.WriteUnlock()
+					close(acquired)
+				}()
+				select {
+				case <-acquired:
+				case <-time.After(5 * time.Second):
+					t.Fatal("writer starved by a steady stream of readers")
+				}
+			}
+			close(stop)
+			wg.Wait()
+		})
+	}
+}
+
+/*
+a reader arriving during a write phase waits for that phase to end and then gets in, even though
+more writers queued up behind it in the meantime. with WritePreferring it would have to wait for
+them too.
+*/
+func TestPhaseFairReaderWaitsOneWritePhase(t *testing.T) {
+	rw := NewReadWriteMutex(WithPolicy(PhaseFair))
+	rw.WriteLock()
+
+	var order []string
+	var mutex sync.Mutex
+	record := func(s string) {
+		mutex.Lock()
+		order = append(order, s)
+		mutex.Unlock()
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rw.ReadLock()
+		record("reader")
+		rw.ReadUnlock()
+	}()
+	waitFor(t, rw, "the reader to wait", func() bool { return rw.readersWaiting == 1 })
+
+	for i := range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rw.WriteLock()
+			record("writer")
+			rw.WriteUnlock()
+		}()
+		waitFor(t, rw, "the writers to wait", func() bool { return rw.writersWaiting == i+1 })
+	}
+
+	rw.WriteUnlock()
+	wg.Wait()
+
+	if len(order) != 4 || order[0] != "reader" {
+		t.Fatalf("expected the reader to get in right after the first write phase, got %v", order)
+	}
+}
@@ -0,0 +1,119 @@
+package rw
+
+import "context"
+
+/*
+a common pattern is to read some shared state, decide it needs to change and then change it.
+with a plain read lock the goroutine has to ReadUnlock() and then WriteLock(), and in between
+another writer can get in and change the state, so the decision has to be made all over again.
+
+an upgradable read lock is a read lock that can be turned into a write lock without letting
+any other writer in between:
+- it can be held together with normal read locks, but only one goroutine can hold the
+  upgradable read lock at a time. if two goroutines could upgrade at the same time, each would
+  be waiting for the other one to stop reading and they would deadlock.
+- Upgrade() blocks new readers and waits for the existing readers to finish. writers are
+  still blocked since we're counted as a reader until we become the writer.
+- Downgrade() turns a write lock back into a read lock, again without letting another writer
+  in between, so a goroutine can keep reading what it just wrote.
+
+ex.
+	rw.UpgradableReadLock()
+	if needsUpdate(state) {
+		rw.Upgrade()
+		update(state)
+		rw.Downgrade()
+		use(state)
+		rw.ReadUnlock()
+	} else {
+		rw.UpgradableReadUnlock()
+	}
+*/
+
+// UpgradableReadLock acquires a read lock that can later be upgraded to a write lock with Upgrade.
+func (rw *ReadWriteMutex) UpgradableReadLock() {
+	rw.cond.L.Lock()
+	rw.checkRecursiveRead()
+
+	/*
+	   claim the upgradable slot first, then wait to read like any other reader.
+	   holding the slot while waiting is fine since it only keeps out other upgraders.
+	*/
+	for rw.upgraderActive {
+		rw.cond.Wait()
+	}
+	rw.upgraderActive = true
+
+	rw.waitToRead(context.Background())
+	rw.readersCounter++
+	rw.trackRead()
+
+	rw.cond.L.Unlock()
+}
+
+// UpgradableReadUnlock releases an upgradable read lock that wasn't upgraded.
+func (rw *ReadWriteMutex) UpgradableReadUnlock() {
+	rw.cond.L.Lock()
+	rw.checkUpgradableHeld("UpgradableReadUnlock")
+
+	rw.upgraderActive = false
+	rw.untrackRead()
+	rw.readersCounter--
+
+	// wakes up writers if we were the last reader, and any goroutine waiting for the upgradable slot
+	rw.cond.Broadcast()
+
+	rw.cond.L.Unlock()
+}
+
+/*
+Upgrade turns the upgradable read lock held by the current goroutine into a write lock.
+it waits for the other readers to finish, and no other writer can get the lock in between.
+release it with WriteUnlock() or turn it back into a read lock with Downgrade().
+*/
+func (rw *ReadWriteMutex) Upgrade() {
+	rw.cond.L.Lock()
+	rw.checkUpgradableHeld("Upgrade")
+
+	/*
+	   besides the readers already in, a PhaseFair lock can have readers that were let in by the
+	   last write phase but haven't run yet (readersReady). they don't check for a writer again once
+	   their phase has ended, so becoming the writer before they're in would let them in with us,
+	   same as canWrite() waits for them. each of them increments readersCounter when it gets in, so
+	   the ReadUnlock() that brings the count back down broadcasts and wakes us up to check again.
+	*/
+	rw.upgrading = true
+	for rw.readersCounter > 1 || rw.readersReady > 0 {
+		rw.cond.Wait()
+	}
+	rw.upgrading = false
+
+	// stop being a reader and become the writer in one step, while holding the cond lock
+	rw.untrackRead()
+	rw.readersCounter--
+	rw.writerActive = true
+	rw.upgraderActive = false
+
+	rw.cond.L.Unlock()
+}
+
+/*
+Downgrade turns the write lock held by the current goroutine into a read lock
+without letting any other writer in between. release it with ReadUnlock().
+*/
+func (rw *ReadWriteMutex) Downgrade() {
+	rw.cond.L.Lock()
+	rw.checkWriteHeld("Downgrade")
+
+	rw.writerActive = false
+	rw.readersCounter++
+	rw.trackRead()
+	if rw.policy == PhaseFair {
+		rw.endWritePhase()
+	}
+
+	// other readers can join us now, writers are still blocked until readersCounter gets back to 0
+	rw.cond.Broadcast()
+
+	rw.cond.L.Unlock()
+}
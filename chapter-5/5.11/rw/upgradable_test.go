@@ -0,0 +1,96 @@
+package rw
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+/*
+a write phase ends while plain readers and an upgrader are all waiting for it, so they're let in together.
+for PhaseFair, the upgrader used to become the writer before the other readers had run, and they then
+got in while it was writing.
+*/
+func TestUpgradeIsExclusive(t *testing.T) {
+	for _, p := range policies {
+		t.Run(p.name, func(t *testing.T) {
+			for range 100 {
+				rw := NewReadWriteMutex(WithPolicy(p.policy))
+				var readers, writers atomic.Int32
+
+				rw.WriteLock()
+				wg := sync.WaitGroup{}
+				for range 4 {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						rw.ReadLock()
+						readers.Add(1)
+						time.Sleep(100 * time.Microsecond)
+						if writers.Load() != 0 {
+							t.Error("reader overlapped the upgraded writer")
+						}
+						readers.Add(-1)
+						rw.ReadUnlock()
+					}()
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					rw.UpgradableReadLock()
+					rw.Upgrade()
+					writers.Add(1)
+					time.Sleep(100 * time.Microsecond)
+					if readers.Load() != 0 {
+						t.Error("upgraded writer overlapped a reader")
+					}
+					writers.Add(-1)
+					rw.WriteUnlock()
+				}()
+				waitFor(t, rw, "the readers to wait", func() bool {
+					return rw.readersWaiting == 5 || (p.policy != PhaseFair && rw.upgraderActive)
+				})
+				rw.WriteUnlock()
+				wg.Wait()
+				if t.Failed() {
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestUpgradeAndDowngrade(t *testing.T) {
+	rw := NewReadWriteMutex()
+	rw.UpgradableReadLock()
+	rw.ReadLock()
+	if rw.TryWriteLock() {
+		t.Fatal("write lock taken while readers hold the lock")
+	}
+
+	upgraded := make(chan struct{})
+	go func() {
+		rw.Upgrade()
+		close(upgraded)
+	}()
+	waitFor(t, rw, "the upgrade to start", func() bool { return rw.upgrading })
+	if rw.TryReadLock() {
+		t.Fatal("a new reader got in while upgrading")
+	}
+	rw.ReadUnlock()
+	<-upgraded
+
+	rw.Downgrade()
+	if !rw.TryReadLock() {
+		t.Fatal("a reader couldn't join after the downgrade")
+	}
+	if rw.TryWriteLock() {
+		t.Fatal("write lock taken after the downgrade")
+	}
+	rw.ReadUnlock()
+	rw.ReadUnlock()
+	if !rw.TryWriteLock() {
+		t.Fatal("write lock not available after all readers left")
+	}
+}
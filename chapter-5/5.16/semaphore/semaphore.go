@@ -1,6 +1,13 @@
 package semaphore
 
-import "sync"
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"semstats"
+)
 
 /*
 implementation of a semaphore using conditions and mutuxes.
@@ -16,60 +23,268 @@ getting the mutual exclusive access and other executions are blocked until that
 semaphores do it by having a permit field that holds the max number of goroutines that can be running
 at any instance of time.
 note: a semphore with 1 permit is effectively a mutex since it allows only one goroutine to run at a time.
+
+the first version of this semaphore blocked goroutines with a sync.Cond. the issue with that is
+a goroutine blocked on cond.Wait() can't be woken up by anything other than a Signal or Broadcast,
+so there was no way to give up on acquiring a permit after a timeout or when a context is cancelled.
+instead, each blocked goroutine now waits on its own channel that is kept in a queue of waiters.
+Release() closes the channel of the waiter at the front of the queue, which has the same effect as
+cond.Signal(), but since it's a channel the waiter can also select on ctx.Done() at the same time.
+
+just like cond.Signal(), waking up a waiter doesn't guarantee it gets the permit. the woken goroutine
+still has to reacquire the mutex and a newly arriving goroutine can grab the permit before it does, so
+under a lot of load some goroutines can end up waiting for a very long time (starvation).
+a fair semaphore (NewFairSemaphore) fixes this by serving waiters strictly in the order they arrived:
+- Release() hands the permit directly to the waiter at the front of the queue instead of
+  incrementing permits, so nobody else can take it in between.
+- a goroutine calling Acquire() can't skip ahead of the queue even if there's a permit free.
 */
 
 /*
 - takes in the an int value to determine the number of concurrent gorountines
 allowed to execute.
-- a condition to get exclusive access to updating the permits and blocking other
-processes when we're out of permits to give
+- a mutex to get exclusive access to updating the permits and the waiters queue
+- waiters is a queue of channels, one for each goroutine that is blocked waiting for a permit
+- fair determines whether permits are handed over to waiters in FIFO order
+- size is the configured capacity. permits can go below zero if the capacity was shrunk while goroutines were holding permits
+- stats is optional and records how long goroutines wait for permits and who's holding them
+- strict makes Release() panic when there's no permit held to release
+
+since permits only ever go up by one on every Release(), an extra Release() silently raises the
+number of goroutines that can run at the same time above the capacity. by default that's allowed
+since it's what makes a semaphore with 0 permits usable as a signal (see main). a strict semaphore
+knows that size - permits is the number of permits currently held, and treats a Release() with
+no permits held as a bug.
 */
 type Semaphore struct {
+	size    int
 	permits int
-	cond    *sync.Cond
+	mutex   sync.Mutex
+	waiters list.List
+	fair    bool
+	strict  bool
+	stats   *semstats.Stats
+}
+
+// Option configures optional behaviour of a Semaphore when it's created.
+type Option func(*Semaphore)
+
+// WithStats reports every acquire and release of the semaphore to stats.
+func WithStats(stats *semstats.Stats) Option {
+	return func(rw *Semaphore) {
+		rw.stats = stats
+	}
+}
+
+// WithStrictRelease makes Release() panic if it's called more times than the permits that were acquired.
+func WithStrictRelease() Option {
+	return func(rw *Semaphore) {
+		rw.strict = true
+	}
 }
 
-func NewSemaphore(n int) *Semaphore {
-	return &Semaphore{
+func NewSemaphore(n int, opts ...Option) *Semaphore {
+	rw := &Semaphore{
+		size:    n,
 		permits: n,
-		cond:    sync.NewCond(&sync.Mutex{}),
 	}
+	for _, opt := range opts {
+		opt(rw)
+	}
+	return rw
+}
+
+// NewFairSemaphore creates a semaphore that hands out permits strictly in the order goroutines asked for them.
+func NewFairSemaphore(n int, opts ...Option) *Semaphore {
+	rw := NewSemaphore(n, opts...)
+	rw.fair = true
+	return rw
 }
 
 func (rw *Semaphore) Acquire() {
-	rw.cond.L.Lock() // the condition will acquire the lock
+	/*
+	   a background context is never cancelled so its Done() channel is nil,
+	   meaning AcquireContext will only return once a permit was acquired.
+	*/
+	rw.AcquireContext(context.Background())
+}
+
+/*
+AcquireContext blocks until a permit is acquired or the context is done.
+if the context is done first, ctx.Err() is returned and no permit is held.
+*/
+func (rw *Semaphore) AcquireContext(ctx context.Context) error {
+	start := time.Now()
+	rw.mutex.Lock()
+
+	// don't hand out a permit to a goroutine that has already given up
+	if err := ctx.Err(); err != nil {
+		rw.mutex.Unlock()
+		return err
+	}
 
 	/*
-	   if a goroutine is attempting to acquire access and we're out of permits
-	   then the goroutine that's attemtping to acquire access will have to wait.
+	   a fair semaphore only lets the current goroutine take a free permit right away
+	   if no one else is already waiting in the queue.
 	*/
-	for rw.permits <= 0 {
+	if rw.permits > 0 && (!rw.fair || rw.waiters.Len() == 0) {
+		rw.permits--
+		rw.mutex.Unlock()
+		rw.acquired(start)
+		return nil
+	}
+
+	for {
 		/*
-		   the goroutine that's waiting will release the condition lock so another
-		   goroutine can acquire access. once that current goroutine get a signal,
-		   it will acquire the condition lock again and run through the loop again.
-		   if there's permits > 0, then we will escape the for loop and not .Wait().
-		   we decrement permits since the current goroutine will have acquired access
-		   to the semaphore and release the condition lock so another goroutine can
-		   attempt to Acquire or Release their access to semaphore.
+		   same as the cond.Wait() loop. we queue up a channel for the current goroutine and
+		   release the lock so other goroutines can Acquire or Release. once our channel is closed
+		   we acquire the lock again and check the permits again since another goroutine could
+		   have taken the permit before we got the lock back.
 		*/
-		rw.cond.Wait()
+		ready := make(chan struct{})
+		elem := rw.waiters.PushBack(ready)
+		rw.mutex.Unlock()
+
+		select {
+		case <-ready:
+			// for a fair semaphore the permit was handed to us directly so it's already ours
+			if rw.fair {
+				rw.acquired(start)
+				return nil
+			}
+			rw.mutex.Lock()
+			if rw.permits > 0 {
+				rw.permits--
+				rw.mutex.Unlock()
+				rw.acquired(start)
+				return nil
+			}
+		case <-ctx.Done():
+			rw.mutex.Lock()
+			select {
+			case <-ready:
+				/*
+				   we were woken up at the same time we gave up. the wake up is passed on
+				   to the next waiter so it doesn't get lost. for a fair semaphore we were
+				   also handed a permit, so we give it back before passing it on.
+				*/
+				if rw.fair {
+					rw.permits++
+				}
+			default:
+				rw.waiters.Remove(elem)
+			}
+			rw.wake()
+			rw.mutex.Unlock()
+			return ctx.Err()
+		}
+	}
+}
+
+// TryAcquire acquires a permit only if one is available right now and reports whether it did.
+func (rw *Semaphore) TryAcquire() bool {
+	rw.mutex.Lock()
+	if rw.permits <= 0 || (rw.fair && rw.waiters.Len() > 0) {
+		rw.mutex.Unlock()
+		return false
 	}
 	rw.permits--
-	rw.cond.L.Unlock()
+	rw.mutex.Unlock()
+
+	rw.acquired(time.Now())
+	return true
+}
+
+// AcquireTimeout waits at most d for a permit and reports whether one was acquired.
+func (rw *Semaphore) AcquireTimeout(d time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return rw.AcquireContext(ctx) == nil
 }
 
 func (rw *Semaphore) Release() {
-	rw.cond.L.Lock()
+	rw.mutex.Lock()
+	if rw.strict && rw.size-rw.permits <= 0 {
+		rw.mutex.Unlock()
+		panic("semaphore: Release called without a matching Acquire")
+	}
 	/*
 	   when a goroutine releases its access to the semaphore, it just increment
-	   the permits and call .Signal(). Since all of our .Waits() within the
-	   semaphore are goroutines attempting to acquire access, we call .Signal()
-	   and let a random goroutine have access to it since we've only incremented
-	   the permit by one.
+	   the permits and wakes up a waiting goroutine. since we've only incremented
+	   the permit by one, only one goroutine needs to be woken up.
 	*/
 	rw.permits++
 
-	rw.cond.Signal()
-	rw.cond.L.Unlock()
+	rw.wake()
+	rw.mutex.Unlock()
+
+	if rw.stats != nil {
+		rw.stats.Released(1)
+	}
+}
+
+// records a successful acquire that started waiting at start, if the semaphore is instrumented.
+func (rw *Semaphore) acquired(start time.Time) {
+	if rw.stats != nil {
+		rw.stats.Acquired(1, time.Since(start))
+	}
+}
+
+/*
+SetCapacity changes the number of goroutines that can hold a permit at the same time.
+growing the capacity wakes up waiters straight away. shrinking it doesn't take permits
+away from the goroutines already holding them, permits just go below zero so new
+acquisitions are blocked until enough of the current holders have released.
+panics if n is negative.
+*/
+func (rw *Semaphore) SetCapacity(n int) {
+	if n < 0 {
+		panic("semaphore: negative capacity")
+	}
+
+	rw.mutex.Lock()
+	rw.permits += n - rw.size
+	rw.size = n
+	rw.wake()
+	rw.mutex.Unlock()
+}
+
+// Capacity returns the configured number of permits.
+func (rw *Semaphore) Capacity() int {
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+	return rw.size
+}
+
+// Available returns the number of permits that can be acquired right now.
+func (rw *Semaphore) Available() int {
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+	return max(rw.permits, 0)
+}
+
+// Waiting returns the number of goroutines blocked waiting for a permit.
+func (rw *Semaphore) Waiting() int {
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+	return rw.waiters.Len()
+}
+
+/*
+wakes up as many waiters as there are permits available, starting from the front
+of the queue. must be called with the mutex held.
+for a fair semaphore the permits are handed over to the woken waiters, otherwise
+the woken waiters have to compete for them with any newly arriving goroutines.
+*/
+func (rw *Semaphore) wake() {
+	if rw.fair {
+		for rw.permits > 0 && rw.waiters.Len() > 0 {
+			rw.permits--
+			close(rw.waiters.Remove(rw.waiters.Front()).(chan struct{}))
+		}
+		return
+	}
+	for i := 0; i < rw.permits && rw.waiters.Len() > 0; i++ {
+		close(rw.waiters.Remove(rw.waiters.Front()).(chan struct{}))
+	}
 }
@@ -0,0 +1,365 @@
+package semaphore
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waits until n goroutines are queued up on the semaphore
+func waitForWaiters(t *testing.T, sema *Semaphore, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for sema.Waiting() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d waiters, got %d", n, sema.Waiting())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// the unfair and the fair semaphore, for tests that apply to both
+var kinds = []struct {
+	name         string
+	newSemaphore func(n int, opts ...Option) *Semaphore
+}{
+	{"unfair", NewSemaphore},
+	{"fair", NewFairSemaphore},
+}
+
+// checks that every permit is free again and nobody is left waiting
+func expectIdle(t *testing.T, sema *Semaphore, permits int) {
+	t.Helper()
+	if sema.Available() != permits || sema.Waiting() != 0 {
+		t.Fatalf("expected %d free permits and no waiters, got %d free and %d waiting",
+			permits, sema.Available(), sema.Waiting())
+	}
+}
+
+func TestTryAcquire(t *testing.T) {
+	for _, kind := range kinds {
+		sema := kind.newSemaphore(2)
+		if !sema.TryAcquire() || !sema.TryAcquire() {
+			t.Fatalf("%s: expected both permits to be acquired", kind.name)
+		}
+		if sema.TryAcquire() {
+			t.Fatalf("%s: acquired a permit while none were free", kind.name)
+		}
+		sema.Release()
+		sema.Release()
+		expectIdle(t, sema, 2)
+	}
+}
+
+func TestAcquireTimeout(t *testing.T) {
+	for _, kind := range kinds {
+		sema := kind.newSemaphore(1)
+		sema.Acquire()
+		if sema.AcquireTimeout(10 * time.Millisecond) {
+			t.Fatalf("%s: acquired a permit that was held", kind.name)
+		}
+		// the acquire that timed out left nothing behind
+		expectIdle(t, sema, 0)
+
+		time.AfterFunc(10*time.Millisecond, sema.Release)
+		if !sema.AcquireTimeout(5 * time.Second) {
+			t.Fatalf("%s: permit released in time wasn't acquired", kind.name)
+		}
+		sema.Release()
+		expectIdle(t, sema, 1)
+	}
+}
+
+func TestAcquireContextAlreadyDone(t *testing.T) {
+	for _, kind := range kinds {
+		sema := kind.newSemaphore(1)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// a free permit isn't handed to a goroutine that has already given up
+		if err := sema.AcquireContext(ctx); !errors.Is(err, context.Canceled) {
+			t.Fatalf("%s: expected context.Canceled, got %v", kind.name, err)
+		}
+		expectIdle(t, sema, 1)
+	}
+}
+
+/*
+ctx is cancelled and the permit released before the waiter gets to run, so its select sees both
+at once and it's sometimes woken up (or for a fair semaphore handed the permit) after giving up. either the waiter gets the permit or the
+permit goes to the waiter queued behind it, it's never lost.
+*/
+func TestCancelledAcquireRacingReleaseKeepsPermits(t *testing.T) {
+	for _, kind := range kinds {
+		for range 300 {
+			sema := kind.newSemaphore(1)
+			sema.Acquire()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			result := make(chan error)
+			go func() { result <- sema.AcquireContext(ctx) }()
+			waitForWaiters(t, sema, 1)
+
+			behind := make(chan struct{})
+			go func() {
+				sema.Acquire()
+				close(behind)
+			}()
+			waitForWaiters(t, sema, 2)
+
+			cancel()
+			sema.Release()
+
+			if err := <-result; err == nil {
+				sema.Release()
+			}
+			select {
+			case <-behind:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%s: the waiter behind the cancelled one never got the permit", kind.name)
+			}
+			sema.Release()
+			expectIdle(t, sema, 1)
+		}
+	}
+}
+
+func TestFairSemaphoreServesWaitersInOrder(t *testing.T) {
+	const waiters = 20
+	sema := NewFairSemaphore(1)
+	sema.Acquire()
+
+	order := make(chan int, waiters)
+	wg := sync.WaitGroup{}
+	for i := range waiters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sema.Acquire()
+			order <- i
+			sema.Release()
+		}()
+		// the next goroutine only starts once this one is queued, so the arrival order is known
+		waitForWaiters(t, sema, i+1)
+	}
+
+	sema.Release()
+	wg.Wait()
+	close(order)
+
+	next := 0
+	for i := range order {
+		if i != next {
+			t.Fatalf("waiter %d acquired before waiter %d", i, next)
+		}
+		next++
+	}
+}
+
+/*
+a goroutine that keeps acquiring and releasing is the one that starves the waiters of an unfair
+semaphore. with a fair one it has to queue up behind them, so a waiter is overtaken at most once
+per goroutine queued in front of it.
+*/
+func TestFairSemaphoreBoundsWait(t *testing.T) {
+	sema := NewFairSemaphore(1)
+	sema.Acquire()
+
+	var mutex sync.Mutex
+	overtakes := 0
+	waiterDone := false
+
+	stop := make(chan struct{})
+	bargerDone := make(chan struct{})
+	go func() {
+		defer close(bargerDone)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			sema.Acquire()
+			mutex.Lock()
+			if !waiterDone {
+				overtakes++
+			}
+			mutex.Unlock()
+			sema.Release()
+		}
+	}()
+	waitForWaiters(t, sema, 1)
+
+	acquired := make(chan struct{})
+	go func() {
+		sema.Acquire()
+		mutex.Lock()
+		waiterDone = true
+		mutex.Unlock()
+		sema.Release()
+		close(acquired)
+	}()
+	waitForWaiters(t, sema, 2)
+
+	sema.Release()
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiter starved by a goroutine repeatedly acquiring the semaphore")
+	}
+	close(stop)
+	<-bargerDone
+
+	// the barger was queued in front of the waiter, so it can get the permit once before it
+	if overtakes > 1 {
+		t.Fatalf("waiter was overtaken %d times", overtakes)
+	}
+}
+
+func TestFairSemaphoreTryAcquireDoesNotSkipQueue(t *testing.T) {
+	sema := NewFairSemaphore(1)
+	sema.Acquire()
+
+	acquired := make(chan struct{})
+	go func() {
+		sema.Acquire()
+		close(acquired)
+	}()
+	waitForWaiters(t, sema, 1)
+
+	// the permit is handed straight to the waiter, so it can't be taken before the waiter runs
+	sema.Release()
+	if sema.TryAcquire() {
+		t.Fatal("TryAcquire took the permit handed to the waiter")
+	}
+	<-acquired
+}
+
+func expectPanic(t *testing.T, name string, f func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("%s didn't panic", name)
+		}
+	}()
+	f()
+}
+
+func TestStrictReleaseKeepsHoldersWithinCapacity(t *testing.T) {
+	const capacity = 3
+	sema := NewSemaphore(capacity, WithStrictRelease())
+
+	var mutex sync.Mutex
+	holders, maxHolders := 0, 0
+	wg := sync.WaitGroup{}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 200 {
+				sema.Acquire()
+				mutex.Lock()
+				holders++
+				maxHolders = max(maxHolders, holders)
+				mutex.Unlock()
+
+				runtime.Gosched()
+
+				mutex.Lock()
+				holders--
+				mutex.Unlock()
+				sema.Release()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxHolders > capacity {
+		t.Fatalf("%d goroutines held a permit at once with a capacity of %d", maxHolders, capacity)
+	}
+
+	// every permit is back, so one more release would let capacity+1 goroutines in
+	expectPanic(t, "extra Release", sema.Release)
+	for range capacity {
+		if !sema.TryAcquire() {
+			t.Fatal("expected a permit to be available")
+		}
+	}
+	if sema.TryAcquire() {
+		t.Fatal("acquired more permits than the capacity")
+	}
+}
+
+// without strict mode the same extra release silently raises the limit, which is what strict mode prevents
+func TestExtraReleaseRaisesLimitWithoutStrictMode(t *testing.T) {
+	sema := NewSemaphore(1)
+	sema.Release()
+	if !sema.TryAcquire() || !sema.TryAcquire() {
+		t.Fatal("expected the extra release to raise the limit")
+	}
+}
+
+func TestGrowingCapacityWakesWaiters(t *testing.T) {
+	for _, kind := range kinds {
+		sema := kind.newSemaphore(1)
+		sema.Acquire()
+
+		wg := sync.WaitGroup{}
+		for i := range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sema.Acquire()
+			}()
+			waitForWaiters(t, sema, i+1)
+		}
+
+		sema.SetCapacity(3)
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: waiters weren't woken up by the capacity growing", kind.name)
+		}
+		expectIdle(t, sema, 0)
+	}
+}
+
+func TestShrinkingCapacityOnlyLimitsNewAcquires(t *testing.T) {
+	for _, kind := range kinds {
+		sema := kind.newSemaphore(3, WithStrictRelease())
+		for range 3 {
+			sema.Acquire()
+		}
+
+		// the holders keep their permits, and have to give back 2 of them before anyone else gets one
+		sema.SetCapacity(1)
+		if sema.Capacity() != 1 || sema.Available() != 0 {
+			t.Fatalf("%s: expected capacity 1 with 0 available, got %d with %d",
+				kind.name, sema.Capacity(), sema.Available())
+		}
+		sema.Release()
+		sema.Release()
+		if sema.TryAcquire() {
+			t.Fatalf("%s: acquired a permit while the holders were still above the new capacity", kind.name)
+		}
+		sema.Release()
+		if !sema.TryAcquire() || sema.TryAcquire() {
+			t.Fatalf("%s: expected exactly one permit to be available", kind.name)
+		}
+	}
+}
+
+func TestNegativeCapacityPanics(t *testing.T) {
+	sema := NewSemaphore(1)
+	expectPanic(t, "SetCapacity(-1)", func() { sema.SetCapacity(-1) })
+	if sema.Capacity() != 1 {
+		t.Fatalf("expected capacity to stay 1, got %d", sema.Capacity())
+	}
+}
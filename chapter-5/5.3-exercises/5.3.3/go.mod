@@ -1,3 +1,13 @@
 module 5.3.3
 
 go 1.22.2
+
+require (
+	semstats v0.0.0
+	syncutil v0.0.0
+)
+
+replace (
+	semstats => ../../semstats
+	syncutil => ../../syncutil
+)
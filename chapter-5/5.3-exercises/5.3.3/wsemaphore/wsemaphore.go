@@ -1,53 +1,293 @@
 package wsemaphore
 
-import "sync"
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
 
+	"semstats"
+)
+
+/*
+the first version of the weighted semaphore woke up every blocked goroutine with Broadcast()
+and had each of them recheck `permitsRequired > ws.permits`. the issue with that is a goroutine
+that needs a lot of permits can be starved forever, since goroutines that need a few permits
+keep arriving and taking whatever gets released before the big request ever fits.
+
+this version works like golang.org/x/sync/semaphore:
+- blocked goroutines are kept in a queue of waiters and are served in the order they arrived.
+- a waiter at the front of the queue that needs more permits than are available blocks all
+  the waiters behind it, even if they need less permits. this way a large request will
+  eventually get its permits.
+- permits are handed over directly to a waiter by Release(), so nothing can take them in between.
+*/
+
+// ErrExceedsCapacity is returned when more permits are requested than the semaphore will ever have.
+var ErrExceedsCapacity = errors.New("wsemaphore: requested permits exceed the semaphore capacity")
+
+/*
+ErrInvalidPermits is returned when asking for zero or a negative number of permits. acquiring
+a negative number would raise the permits instead of taking them, getting around strict mode.
+*/
+var ErrInvalidPermits = errors.New("wsemaphore: permits must be greater than zero")
+
+/*
+a goroutine that is blocked waiting for permits.
+ready is closed once the permits were handed over, or with err set
+if the request can no longer be satisfied.
+*/
+type waiter struct {
+	permitsRequired int
+	ready           chan struct{}
+	err             error
+}
+
+/*
+size is the configured capacity of the semaphore. permits is what's left to be given out
+and can go below zero if the capacity was shrunk while goroutines were holding permits.
+stats is optional and records how long goroutines wait for permits and who's holding them.
+strict makes Release() panic when releasing more permits than are held, since size - permits
+is the number of permits held and an extra release would raise the limit above the capacity.
+*/
 type WeightedSemaphore struct {
-	cond    *sync.Cond
+	mutex   sync.Mutex
+	size    int
 	permits int
+	waiters list.List
+	strict  bool
+	stats   *semstats.Stats
+}
+
+// Option configures optional behaviour of a WeightedSemaphore when it's created.
+type Option func(*WeightedSemaphore)
+
+// WithStats reports every acquire and release of the semaphore to stats.
+func WithStats(stats *semstats.Stats) Option {
+	return func(ws *WeightedSemaphore) {
+		ws.stats = stats
+	}
+}
+
+// WithStrictRelease makes Release() panic if it releases more permits than were acquired.
+func WithStrictRelease() Option {
+	return func(ws *WeightedSemaphore) {
+		ws.strict = true
+	}
 }
 
-func NewWeightedSemaphore(permits int) *WeightedSemaphore {
-	return &WeightedSemaphore{
-		cond:    sync.NewCond(&sync.Mutex{}),
+func NewWeightedSemaphore(permits int, opts ...Option) *WeightedSemaphore {
+	ws := &WeightedSemaphore{
+		size:    permits,
 		permits: permits,
 	}
+	for _, opt := range opts {
+		opt(ws)
+	}
+	return ws
+}
+
+func (ws *WeightedSemaphore) Acquire(permitsRequired int) error {
+	return ws.AcquireContext(context.Background(), permitsRequired)
 }
 
-func (ws *WeightedSemaphore) Acquire(permitsRequired int) {
-	ws.cond.L.Lock()
+/*
+AcquireContext blocks until permitsRequired permits are acquired or the context is done.
+if the context is done first, ctx.Err() is returned and no permits are held.
+*/
+func (ws *WeightedSemaphore) AcquireContext(ctx context.Context, permitsRequired int) error {
+	if permitsRequired <= 0 {
+		return ErrInvalidPermits
+	}
+
+	start := time.Now()
+	ws.mutex.Lock()
 
 	/*
-	   Since we're doing a weighted semaphore that gets passed in
-	   the value of the permit a goroutine wants to use, we have to
-	   check that the semaphore has the amount of permits to give.
-	   If not, the goroutine will Wait() until the amount is available.
+	   if we were to queue up a request that can never be satisfied it would block
+	   every waiter behind it forever, so fail straight away.
 	*/
-	for permitsRequired > ws.permits {
-		ws.cond.Wait()
+	if permitsRequired > ws.size {
+		ws.mutex.Unlock()
+		return ErrExceedsCapacity
+	}
+
+	if err := ctx.Err(); err != nil {
+		ws.mutex.Unlock()
+		return err
 	}
 
+	// only take the permits right away if there's enough of them and no one is ahead of us
+	if permitsRequired <= ws.permits && ws.waiters.Len() == 0 {
+		ws.permits -= permitsRequired
+		ws.mutex.Unlock()
+		ws.acquired(permitsRequired, start)
+		return nil
+	}
+
+	w := &waiter{permitsRequired: permitsRequired, ready: make(chan struct{})}
+	elem := ws.waiters.PushBack(w)
+	ws.mutex.Unlock()
+
+	select {
+	case <-w.ready:
+		if w.err == nil {
+			ws.acquired(permitsRequired, start)
+		}
+		return w.err
+	case <-ctx.Done():
+		ws.mutex.Lock()
+		defer ws.mutex.Unlock()
+		select {
+		case <-w.ready:
+			if w.err != nil {
+				return w.err
+			}
+			/*
+			   the permits were handed to us at the same time we gave up,
+			   so give them back for the other waiters.
+			*/
+			ws.permits += permitsRequired
+		default:
+			ws.waiters.Remove(elem)
+		}
+		/*
+		   if we were at the front of the queue we might have been the one blocking
+		   the waiters behind us, so check if they can have their permits now.
+		*/
+		ws.notifyWaiters()
+		return ctx.Err()
+	}
+}
+
+/*
+TryAcquire acquires permitsRequired permits only if they are available right now
+and no other goroutine is waiting ahead of us. reports whether the permits were acquired.
+asking for zero or a negative number of permits never succeeds.
+*/
+func (ws *WeightedSemaphore) TryAcquire(permitsRequired int) bool {
+	if permitsRequired <= 0 {
+		return false
+	}
+
+	ws.mutex.Lock()
+	if permitsRequired > ws.permits || ws.waiters.Len() > 0 {
+		ws.mutex.Unlock()
+		return false
+	}
 	ws.permits -= permitsRequired
+	ws.mutex.Unlock()
 
-	ws.cond.L.Unlock()
+	ws.acquired(permitsRequired, time.Now())
+	return true
 }
 
+/*
+Release gives back permitsReleased permits. releasing zero or a negative number of permits
+is always a bug, so it panics whether or not the semaphore is strict.
+*/
 func (ws *WeightedSemaphore) Release(permitsReleased int) {
-	ws.cond.L.Lock()
+	if permitsReleased <= 0 {
+		panic("wsemaphore: Release called with zero or negative permits")
+	}
+
+	ws.mutex.Lock()
+
+	if ws.strict && permitsReleased > ws.size-ws.permits {
+		ws.mutex.Unlock()
+		panic("wsemaphore: Release called with more permits than were acquired")
+	}
 
 	ws.permits += permitsReleased
 
 	/*
-	   using Broadcast() so all blocked goroutines can attempt to acquire permits.
-	   since this is weighted, the current goroutine may have 5 permits and releasing
-	   them can allow all 5 goroutines that all have 1 permit required to process.
-	   for a normal semaphore where each goroutine only requires 1 permit, we used .Signal()
-	   to only wake one goroutine that's blocked since each goroutine only needed one to run.
-	   since each goroutine in a weighted semaphore has a variable permit requirement,
-	   we have to wake all existing blocked goroutines because there's a change all can process
-	   due to the high weighted goroutine releasing its permits.
+	   the current goroutine may have 5 permits and releasing them can allow
+	   all 5 goroutines that all require 1 permit to process, so we keep handing
+	   permits to waiters from the front of the queue until one doesn't fit.
 	*/
-	ws.cond.Broadcast()
+	ws.notifyWaiters()
+
+	ws.mutex.Unlock()
 
-	ws.cond.L.Unlock()
+	if ws.stats != nil {
+		ws.stats.Released(permitsReleased)
+	}
+}
+
+// records a successful acquire that started waiting at start, if the semaphore is instrumented.
+func (ws *WeightedSemaphore) acquired(permits int, start time.Time) {
+	if ws.stats != nil {
+		ws.stats.Acquired(permits, time.Since(start))
+	}
+}
+
+/*
+SetCapacity changes the total number of permits of the semaphore.
+growing the capacity hands the new permits to the waiters straight away. shrinking it
+doesn't take permits away from the goroutines already holding them, it only limits new
+acquisitions until enough permits have been released. waiters that need more permits
+than the new capacity are woken up with ErrExceedsCapacity since they could never proceed.
+panics if n is negative.
+*/
+func (ws *WeightedSemaphore) SetCapacity(n int) {
+	if n < 0 {
+		panic("wsemaphore: negative capacity")
+	}
+
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	ws.permits += n - ws.size
+	ws.size = n
+
+	for elem := ws.waiters.Front(); elem != nil; {
+		next := elem.Next()
+		if w := elem.Value.(*waiter); w.permitsRequired > n {
+			w.err = ErrExceedsCapacity
+			ws.waiters.Remove(elem)
+			close(w.ready)
+		}
+		elem = next
+	}
+	ws.notifyWaiters()
+}
+
+// Capacity returns the configured number of permits.
+func (ws *WeightedSemaphore) Capacity() int {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+	return ws.size
+}
+
+// Available returns the number of permits that can be acquired right now.
+func (ws *WeightedSemaphore) Available() int {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+	return max(ws.permits, 0)
+}
+
+// Waiting returns the number of goroutines blocked waiting for permits.
+func (ws *WeightedSemaphore) Waiting() int {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+	return ws.waiters.Len()
+}
+
+/*
+hands permits over to the waiters in the order they arrived. stops at the first waiter
+that needs more permits than are available so it can't be starved by the ones behind it.
+must be called with the mutex held.
+*/
+func (ws *WeightedSemaphore) notifyWaiters() {
+	for ws.waiters.Len() > 0 {
+		front := ws.waiters.Front()
+		w := front.Value.(*waiter)
+		if w.permitsRequired > ws.permits {
+			break
+		}
+		ws.permits -= w.permitsRequired
+		ws.waiters.Remove(front)
+		close(w.ready)
+	}
 }
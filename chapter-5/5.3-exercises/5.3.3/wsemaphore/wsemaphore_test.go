@@ -0,0 +1,350 @@
+package wsemaphore
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waits until n goroutines are queued up on the semaphore
+func waitForWaiters(t *testing.T, ws *WeightedSemaphore, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for ws.Waiting() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d waiters, got %d", n, ws.Waiting())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// starts a goroutine acquiring permits, the returned channel gets the result of the acquire
+func acquireAsync(ctx context.Context, ws *WeightedSemaphore, permits int) chan error {
+	result := make(chan error, 1)
+	go func() { result <- ws.AcquireContext(ctx, permits) }()
+	return result
+}
+
+func expectResult(t *testing.T, name string, result chan error, want error) {
+	t.Helper()
+	select {
+	case err := <-result:
+		if !errors.Is(err, want) {
+			t.Fatalf("%s: expected %v, got %v", name, want, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s: still blocked", name)
+	}
+}
+
+func expectBlocked(t *testing.T, name string, result chan error) {
+	t.Helper()
+	select {
+	case err := <-result:
+		t.Fatalf("%s: expected to be blocked, returned %v", name, err)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+// every waiter needs 2 of the 3 permits, so only one of them fits at a time and they have to run in order
+func TestWaitersAreServedInOrder(t *testing.T) {
+	const waiters = 10
+	ws := NewWeightedSemaphore(3)
+	if err := ws.Acquire(3); err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan int, waiters)
+	wg := sync.WaitGroup{}
+	for i := range waiters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := ws.Acquire(2); err != nil {
+				t.Error(err)
+				return
+			}
+			order <- i
+			ws.Release(2)
+		}()
+		// the next goroutine only starts once this one is queued, so the arrival order is known
+		waitForWaiters(t, ws, i+1)
+	}
+
+	ws.Release(3)
+	wg.Wait()
+	close(order)
+
+	next := 0
+	for i := range order {
+		if i != next {
+			t.Fatalf("waiter %d acquired before waiter %d", i, next)
+		}
+		next++
+	}
+}
+
+func TestLargeWaiterBlocksSmallerOnesBehindIt(t *testing.T) {
+	ws := NewWeightedSemaphore(4)
+	if err := ws.Acquire(2); err != nil {
+		t.Fatal(err)
+	}
+
+	large := acquireAsync(context.Background(), ws, 4)
+	waitForWaiters(t, ws, 1)
+	small := acquireAsync(context.Background(), ws, 1)
+	waitForWaiters(t, ws, 2)
+
+	// 2 permits are free, but the small waiter and TryAcquire can't jump ahead of the large one
+	expectBlocked(t, "small waiter", small)
+	if ws.TryAcquire(1) {
+		t.Fatal("TryAcquire skipped ahead of the queue")
+	}
+
+	ws.Release(2)
+	expectResult(t, "large waiter", large, nil)
+	expectBlocked(t, "small waiter", small)
+
+	ws.Release(4)
+	expectResult(t, "small waiter", small, nil)
+	ws.Release(1)
+	if got := ws.Available(); got != 4 {
+		t.Fatalf("expected 4 available, got %d", got)
+	}
+}
+
+func TestAcquireMoreThanCapacity(t *testing.T) {
+	ws := NewWeightedSemaphore(4)
+	if err := ws.Acquire(5); !errors.Is(err, ErrExceedsCapacity) {
+		t.Fatalf("expected ErrExceedsCapacity, got %v", err)
+	}
+	if ws.TryAcquire(5) {
+		t.Fatal("TryAcquire got more permits than the capacity")
+	}
+	// the request wasn't queued, so it doesn't block anyone
+	if err := ws.Acquire(4); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCancelledLargeWaiterUnblocksTheOnesBehindIt(t *testing.T) {
+	ws := NewWeightedSemaphore(4)
+	if err := ws.Acquire(2); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	large := acquireAsync(ctx, ws, 4)
+	waitForWaiters(t, ws, 1)
+	small := acquireAsync(context.Background(), ws, 1)
+	waitForWaiters(t, ws, 2)
+
+	cancel()
+	expectResult(t, "large waiter", large, context.Canceled)
+	expectResult(t, "small waiter", small, nil)
+
+	ws.Release(1)
+	ws.Release(2)
+	if got := ws.Available(); got != 4 || ws.Waiting() != 0 {
+		t.Fatalf("expected 4 available and no waiters, got %d available and %d waiting", got, ws.Waiting())
+	}
+}
+
+/*
+ctx is cancelled and the permits released before the waiter gets to run, so its select sees both
+at once and it's sometimes handed the permits after giving up. it has to give them back, and pass
+them on to the waiter behind it.
+*/
+func TestCancelledAcquireRacingReleaseKeepsPermits(t *testing.T) {
+	for range 300 {
+		ws := NewWeightedSemaphore(3)
+		if err := ws.Acquire(3); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancelled := acquireAsync(ctx, ws, 2)
+		waitForWaiters(t, ws, 1)
+		behind := acquireAsync(context.Background(), ws, 3)
+		waitForWaiters(t, ws, 2)
+
+		cancel()
+		ws.Release(3)
+
+		if err := <-cancelled; err == nil {
+			ws.Release(2)
+		}
+		expectResult(t, "waiter behind the cancelled one", behind, nil)
+		ws.Release(3)
+		if got := ws.Available(); got != 3 || ws.Waiting() != 0 {
+			t.Fatalf("expected 3 available and no waiters, got %d available and %d waiting", got, ws.Waiting())
+		}
+	}
+}
+
+func expectPanic(t *testing.T, name string, f func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("%s didn't panic", name)
+		}
+	}()
+	f()
+}
+
+func TestStrictReleasePanicsWhenReleasingMoreThanHeld(t *testing.T) {
+	ws := NewWeightedSemaphore(3, WithStrictRelease())
+	if err := ws.Acquire(2); err != nil {
+		t.Fatal(err)
+	}
+	expectPanic(t, "Release(3) with 2 held", func() { ws.Release(3) })
+	ws.Release(2)
+	expectPanic(t, "Release(1) with nothing held", func() { ws.Release(1) })
+	if got := ws.Available(); got != 3 {
+		t.Fatalf("expected 3 available, got %d", got)
+	}
+}
+
+func TestStrictReleaseKeepsHeldPermitsWithinCapacity(t *testing.T) {
+	const capacity = 4
+	ws := NewWeightedSemaphore(capacity, WithStrictRelease())
+
+	var mutex sync.Mutex
+	held, maxHeld := 0, 0
+	wg := sync.WaitGroup{}
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			permits := g%capacity + 1
+			for range 200 {
+				if err := ws.Acquire(permits); err != nil {
+					t.Error(err)
+					return
+				}
+				mutex.Lock()
+				held += permits
+				maxHeld = max(maxHeld, held)
+				mutex.Unlock()
+
+				runtime.Gosched()
+
+				mutex.Lock()
+				held -= permits
+				mutex.Unlock()
+				ws.Release(permits)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxHeld > capacity {
+		t.Fatalf("%d permits held at once with a capacity of %d", maxHeld, capacity)
+	}
+
+	// every permit is back, so one more release would raise the limit above the capacity
+	expectPanic(t, "extra Release", func() { ws.Release(1) })
+	if got := ws.Available(); got != capacity {
+		t.Fatalf("expected %d available, got %d", capacity, got)
+	}
+	if ws.TryAcquire(capacity + 1) {
+		t.Fatal("acquired more permits than the capacity")
+	}
+}
+
+// without strict mode the same extra release silently raises the limit, which is what strict mode prevents
+func TestExtraReleaseRaisesLimitWithoutStrictMode(t *testing.T) {
+	ws := NewWeightedSemaphore(1)
+	ws.Release(1)
+	if !ws.TryAcquire(2) {
+		t.Fatal("expected the extra release to raise the limit")
+	}
+}
+
+func TestInvalidPermitsAreRejected(t *testing.T) {
+	ws := NewWeightedSemaphore(2, WithStrictRelease())
+	for _, n := range []int{0, -1} {
+		if err := ws.Acquire(n); !errors.Is(err, ErrInvalidPermits) {
+			t.Fatalf("Acquire(%d) returned %v", n, err)
+		}
+		if ws.TryAcquire(n) {
+			t.Fatalf("TryAcquire(%d) succeeded", n)
+		}
+	}
+	// a negative acquire used to raise the permits above the capacity, even in strict mode
+	if got := ws.Available(); got != 2 {
+		t.Fatalf("expected 2 available, got %d", got)
+	}
+
+	plain := NewWeightedSemaphore(2)
+	expectPanic(t, "Release(-1)", func() { plain.Release(-1) })
+	expectPanic(t, "Release(0)", func() { plain.Release(0) })
+}
+
+func TestGrowingCapacityWakesWaiters(t *testing.T) {
+	ws := NewWeightedSemaphore(2)
+	if err := ws.Acquire(2); err != nil {
+		t.Fatal(err)
+	}
+	first := acquireAsync(context.Background(), ws, 2)
+	waitForWaiters(t, ws, 1)
+	second := acquireAsync(context.Background(), ws, 1)
+	waitForWaiters(t, ws, 2)
+
+	ws.SetCapacity(5)
+	expectResult(t, "first waiter", first, nil)
+	expectResult(t, "second waiter", second, nil)
+	if got := ws.Available(); got != 0 {
+		t.Fatalf("expected 0 available, got %d", got)
+	}
+}
+
+func TestShrinkingCapacityOnlyLimitsNewAcquires(t *testing.T) {
+	ws := NewWeightedSemaphore(4, WithStrictRelease())
+	if err := ws.Acquire(4); err != nil {
+		t.Fatal(err)
+	}
+
+	// the holders keep their permits, and have to give back 3 of them before anyone else gets one
+	ws.SetCapacity(1)
+	if ws.Capacity() != 1 || ws.Available() != 0 {
+		t.Fatalf("expected capacity 1 with 0 available, got %d with %d", ws.Capacity(), ws.Available())
+	}
+	ws.Release(3)
+	if ws.TryAcquire(1) {
+		t.Fatal("acquired a permit while the holders were still above the new capacity")
+	}
+	ws.Release(1)
+	if !ws.TryAcquire(1) || ws.TryAcquire(1) {
+		t.Fatal("expected exactly one permit to be available")
+	}
+}
+
+func TestShrinkingCapacityFailsWaitersThatNoLongerFit(t *testing.T) {
+	ws := NewWeightedSemaphore(4)
+	if err := ws.Acquire(4); err != nil {
+		t.Fatal(err)
+	}
+	large := acquireAsync(context.Background(), ws, 3)
+	waitForWaiters(t, ws, 1)
+	small := acquireAsync(context.Background(), ws, 1)
+	waitForWaiters(t, ws, 2)
+
+	ws.SetCapacity(2)
+	expectResult(t, "large waiter", large, ErrExceedsCapacity)
+	// the small waiter still fits, it waits until the holder is back under the new capacity
+	expectBlocked(t, "small waiter", small)
+	ws.Release(4)
+	expectResult(t, "small waiter", small, nil)
+}
+
+func TestNegativeCapacityPanics(t *testing.T) {
+	ws := NewWeightedSemaphore(1)
+	expectPanic(t, "SetCapacity(-1)", func() { ws.SetCapacity(-1) })
+	if ws.Capacity() != 1 {
+		t.Fatalf("expected capacity to stay 1, got %d", ws.Capacity())
+	}
+}
@@ -0,0 +1,7 @@
+module semstats
+
+go 1.22.2
+
+require syncutil v0.0.0
+
+replace syncutil => ../syncutil
@@ -0,0 +1,208 @@
+package semstats
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"time"
+
+	"syncutil"
+)
+
+/*
+instrumentation shared by the semaphore implementations (5.16 semaphore, 5.3.3 wsemaphore and 7.14 semaphore).
+
+a semaphore that is given a *Stats reports every successful acquire together with how long the goroutine
+had to wait for it, and every release. from that Stats keeps:
+- the number of acquires
+- the total and max time spent waiting for permits
+- a histogram of the wait times, so we can see if most goroutines get a permit right away or
+  if a few of them are stuck for a long time
+
+a Stats created with NewDebugStats also records the stack of every goroutine that currently holds
+permits. Dump() prints them, which helps find the code path that acquired permits and never released them.
+
+note: a semaphore isn't owned by the goroutine that acquired it (ex. in 5.16 main acquires and the child
+goroutine releases), so on release we first look for a holder recorded by the releasing goroutine
+and otherwise release the oldest holder. the number of permits held is always correct, but the stacks
+shown are only a best guess of who is holding them.
+*/
+
+// Buckets are the upper bounds of the wait time histogram. the last bucket holds everything above them.
+var Buckets = []time.Duration{
+	time.Microsecond,
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+/*
+a goroutine holding permits of a semaphore.
+goroutineId is the id of the goroutine that acquired them and stack where it did so.
+*/
+type holder struct {
+	goroutineId uint64
+	permits     int
+	acquiredAt  time.Time
+	stack       []byte
+}
+
+type Stats struct {
+	mutex        sync.Mutex
+	acquires     int64
+	totalWait    time.Duration
+	maxWait      time.Duration
+	histogram    []int64
+	held         int
+	trackHolders bool
+	holders      []*holder
+}
+
+// Snapshot is a copy of the numbers recorded by Stats at some point in time.
+type Snapshot struct {
+	Acquires  int64
+	TotalWait time.Duration
+	MaxWait   time.Duration
+	// Histogram[i] counts the waits <= Buckets[i], the last entry counts the waits above all buckets
+	Histogram []int64
+	// Held is the number of permits acquired and not released yet.
+	// it goes below zero if permits are released before being acquired (ex. 5.16 main)
+	Held int
+}
+
+func NewStats() *Stats {
+	return &Stats{
+		histogram: make([]int64, len(Buckets)+1),
+	}
+}
+
+// NewDebugStats creates a Stats that also records the stack of each goroutine holding permits.
+func NewDebugStats() *Stats {
+	s := NewStats()
+	s.trackHolders = true
+	return s
+}
+
+// Acquired records that permits were acquired after waiting for wait.
+func (s *Stats) Acquired(permits int, wait time.Duration) {
+	var h *holder
+	if s.trackHolders {
+		// capturing the stack is slow, so do it before taking the lock
+		buf := make([]byte, 4096)
+		buf = buf[:runtime.Stack(buf, false)]
+		h = &holder{goroutineId: syncutil.ParseGoroutineId(buf), permits: permits, acquiredAt: time.Now(), stack: buf}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.acquires++
+	s.totalWait += wait
+	s.maxWait = max(s.maxWait, wait)
+
+	bucket := len(Buckets)
+	for i, upperBound := range Buckets {
+		if wait <= upperBound {
+			bucket = i
+			break
+		}
+	}
+	s.histogram[bucket]++
+	s.held += permits
+
+	if h != nil {
+		s.holders = append(s.holders, h)
+	}
+}
+
+// Released records that permits were released.
+func (s *Stats) Released(permits int) {
+	var id uint64
+	if s.trackHolders {
+		id = syncutil.GoroutineId()
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.held -= permits
+	if !s.trackHolders {
+		return
+	}
+
+	// prefer the holders recorded by the current goroutine, then the oldest ones
+	for i := len(s.holders) - 1; i >= 0 && permits > 0; i-- {
+		if s.holders[i].goroutineId == id {
+			permits = s.release(i, permits)
+		}
+	}
+	for len(s.holders) > 0 && permits > 0 {
+		permits = s.release(0, permits)
+	}
+}
+
+/*
+takes up to permits away from holder i, removing it once it holds none.
+returns the permits that are left to release. must be called with the mutex held.
+*/
+func (s *Stats) release(i, permits int) int {
+	h := s.holders[i]
+	released := min(h.permits, permits)
+	h.permits -= released
+	if h.permits == 0 {
+		s.holders = append(s.holders[:i], s.holders[i+1:]...)
+	}
+	return permits - released
+}
+
+func (s *Stats) Snapshot() Snapshot {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return Snapshot{
+		Acquires:  s.acquires,
+		TotalWait: s.totalWait,
+		MaxWait:   s.maxWait,
+		Histogram: append([]int64(nil), s.histogram...),
+		Held:      s.held,
+	}
+}
+
+// AverageWait returns the mean time goroutines waited for their permits.
+func (snap Snapshot) AverageWait() time.Duration {
+	if snap.Acquires == 0 {
+		return 0
+	}
+	return snap.TotalWait / time.Duration(snap.Acquires)
+}
+
+/*
+Dump writes the wait time statistics and, for a Stats created with NewDebugStats,
+the stack of every goroutine still holding permits.
+*/
+func (s *Stats) Dump(w io.Writer) {
+	snap := s.Snapshot()
+	fmt.Fprintf(w, "acquires: %d, held: %d, total wait: %v, avg wait: %v, max wait: %v\n",
+		snap.Acquires, snap.Held, snap.TotalWait, snap.AverageWait(), snap.MaxWait)
+	for i, count := range snap.Histogram {
+		if i < len(Buckets) {
+			fmt.Fprintf(w, "  <= %-8v %d\n", Buckets[i], count)
+		} else {
+			fmt.Fprintf(w, "  >  %-8v %d\n", Buckets[len(Buckets)-1], count)
+		}
+	}
+
+	if !s.trackHolders {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, h := range s.holders {
+		fmt.Fprintf(w, "%d permit(s) held for %v, acquired by:\n%s\n", h.permits, time.Since(h.acquiredAt), h.stack)
+	}
+}
@@ -0,0 +1,164 @@
+package semstats
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHistogramBuckets(t *testing.T) {
+	s := NewStats()
+	// a wait equal to an upper bound goes in that bucket, anything above the last one in the extra bucket
+	waits := []time.Duration{0, time.Microsecond, time.Microsecond + 1, 5 * time.Millisecond, 10 * time.Second, time.Minute}
+	for _, wait := range waits {
+		s.Acquired(1, wait)
+	}
+
+	want := make([]int64, len(Buckets)+1)
+	want[0] = 2            // 0 and 1µs
+	want[1] = 1            // 1µs + 1ns
+	want[4] = 1            // 5ms
+	want[7] = 1            // 10s
+	want[len(Buckets)] = 1 // 1m
+	if got := s.Snapshot().Histogram; !slices.Equal(got, want) {
+		t.Fatalf("expected histogram %v, got %v", want, got)
+	}
+}
+
+func TestWaitTimes(t *testing.T) {
+	s := NewStats()
+	if avg := s.Snapshot().AverageWait(); avg != 0 {
+		t.Fatalf("expected no average wait without acquires, got %v", avg)
+	}
+
+	s.Acquired(1, 10*time.Millisecond)
+	s.Acquired(2, 30*time.Millisecond)
+	s.Acquired(1, 20*time.Millisecond)
+
+	snap := s.Snapshot()
+	if snap.Acquires != 3 {
+		t.Fatalf("expected 3 acquires, got %d", snap.Acquires)
+	}
+	if snap.TotalWait != 60*time.Millisecond || snap.MaxWait != 30*time.Millisecond {
+		t.Fatalf("expected total 60ms and max 30ms, got %v and %v", snap.TotalWait, snap.MaxWait)
+	}
+	if avg := snap.AverageWait(); avg != 20*time.Millisecond {
+		t.Fatalf("expected average 20ms, got %v", avg)
+	}
+}
+
+func TestHeld(t *testing.T) {
+	s := NewStats()
+	s.Acquired(3, 0)
+	s.Released(1)
+	if held := s.Snapshot().Held; held != 2 {
+		t.Fatalf("expected 2 held, got %d", held)
+	}
+	// releasing before acquiring is allowed, like 5.16 main does
+	s.Released(3)
+	if held := s.Snapshot().Held; held != -1 {
+		t.Fatalf("expected -1 held, got %d", held)
+	}
+}
+
+func TestSnapshotIsACopy(t *testing.T) {
+	s := NewStats()
+	snap := s.Snapshot()
+	s.Acquired(1, 0)
+	if snap.Histogram[0] != 0 || snap.Acquires != 0 {
+		t.Fatal("snapshot changed after it was taken")
+	}
+}
+
+// the function name shows up in the stack recorded for the holder
+func holdPermits(s *Stats, permits int) {
+	s.Acquired(permits, 0)
+}
+
+func dump(s *Stats) string {
+	var buf bytes.Buffer
+	s.Dump(&buf)
+	return buf.String()
+}
+
+func TestDumpShowsHolderStacks(t *testing.T) {
+	s := NewDebugStats()
+	holdPermits(s, 2)
+
+	out := dump(s)
+	if !strings.Contains(out, "2 permit(s) held") || !strings.Contains(out, "semstats.holdPermits") {
+		t.Fatalf("expected the holder and its stack in the dump, got:\n%s", out)
+	}
+
+	// a partial release keeps the holder with what it still holds
+	s.Released(1)
+	if out := dump(s); !strings.Contains(out, "1 permit(s) held") {
+		t.Fatalf("expected 1 permit left on the holder, got:\n%s", out)
+	}
+	s.Released(1)
+	if out := dump(s); strings.Contains(out, "held for") {
+		t.Fatalf("expected no holders left, got:\n%s", out)
+	}
+}
+
+func TestDumpWithoutDebugHasNoStacks(t *testing.T) {
+	s := NewStats()
+	holdPermits(s, 1)
+	if out := dump(s); strings.Contains(out, "held for") {
+		t.Fatalf("expected no holder stacks, got:\n%s", out)
+	}
+}
+
+// releases from the goroutine that acquired go to its own holder first, otherwise the oldest holder
+func TestReleasePicksHolder(t *testing.T) {
+	s := NewDebugStats()
+	done := make(chan struct{})
+	go func() {
+		holdPermits(s, 1)
+		close(done)
+	}()
+	<-done
+	s.Acquired(1, 0)
+
+	// the current goroutine releases its own permit, so the other goroutine's is still shown
+	s.Released(1)
+	s.mutex.Lock()
+	if len(s.holders) != 1 || s.holders[0].goroutineId == 0 || !bytes.Contains(s.holders[0].stack, []byte("holdPermits")) {
+		s.mutex.Unlock()
+		t.Fatal("expected the holder left to be the other goroutine")
+	}
+	s.mutex.Unlock()
+
+	// nothing held by the current goroutine anymore, so the oldest holder is released
+	s.Released(1)
+	if out := dump(s); strings.Contains(out, "held for") {
+		t.Fatalf("expected no holders left, got:\n%s", out)
+	}
+}
+
+func TestConcurrentAcquireRelease(t *testing.T) {
+	s := NewDebugStats()
+	wg := sync.WaitGroup{}
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				s.Acquired(2, time.Microsecond)
+				s.Released(2)
+			}
+		}()
+	}
+	wg.Wait()
+
+	snap := s.Snapshot()
+	if snap.Acquires != 800 || snap.Held != 0 || snap.Histogram[0] != 800 {
+		t.Fatalf("expected 800 acquires in the first bucket and nothing held, got %+v", snap)
+	}
+	if out := dump(s); strings.Contains(out, "held for") {
+		t.Fatalf("expected no holders left, got:\n%s", out)
+	}
+}
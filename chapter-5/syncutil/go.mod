@@ -0,0 +1,3 @@
+module syncutil
+
+go 1.22.2
@@ -0,0 +1,67 @@
+package syncutil
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+/*
+helpers shared by the synchronization primitives built in the other chapters. like semstats,
+each example that uses it requires it with a replace directive pointing to this directory.
+*/
+
+/*
+BroadcastOnDone wakes up the goroutines waiting on cond once ctx is done, and returns a function
+that unregisters it again. it's how every cond based primitive with a Context version of a blocking
+method (ex. ReadLockContext in 5.11, WaitContext in 6.3.2) lets its waiters give up.
+
+a goroutine blocked on cond.Wait() can only be woken up by a Signal or Broadcast, so it can't notice
+its context being done by itself. context.AfterFunc calls a function once ctx is done, which
+broadcasts on the cond, and the waiting goroutines then see ctx.Err() on their next check of the loop:
+
+	stop := syncutil.BroadcastOnDone(ctx, cond)
+	defer stop()
+	for !condition {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		cond.Wait()
+	}
+
+the broadcast is done while holding the cond lock so it can't happen between a goroutine checking
+ctx.Err() and calling Wait(), which would be a lost wake up. AfterFunc doesn't start a goroutine
+unless the context is actually done, and calling stop() unregisters it, so nothing is left behind.
+*/
+func BroadcastOnDone(ctx context.Context, cond *sync.Cond) (stop func() bool) {
+	return context.AfterFunc(ctx, func() {
+		cond.L.Lock()
+		cond.Broadcast()
+		cond.L.Unlock()
+	})
+}
+
+/*
+GoroutineId returns the id of the current goroutine. go doesn't expose goroutine ids on purpose,
+this is only meant for debugging and misuse checks that need to tell goroutines apart, like the
+holders tracked by semstats.NewDebugStats or the checked build of the rw package in 5.11.
+*/
+func GoroutineId() uint64 {
+	buf := make([]byte, 64)
+	return ParseGoroutineId(buf[:runtime.Stack(buf, false)])
+}
+
+/*
+ParseGoroutineId parses the goroutine id out of the header of a stack trace captured with
+runtime.Stack, which looks like "goroutine 18 [running]:".
+*/
+func ParseGoroutineId(stack []byte) uint64 {
+	stack = bytes.TrimPrefix(stack, []byte("goroutine "))
+	if i := bytes.IndexByte(stack, ' '); i >= 0 {
+		stack = stack[:i]
+	}
+	id, _ := strconv.ParseUint(string(stack), 10, 64)
+	return id
+}
@@ -1,14 +1,72 @@
 package barrier
 
-import "sync"
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"syncutil"
+)
+
+/*
+the first version of the barrier only counted how many goroutines called Wait() and reset
+waitCount once it reached size. the issue with that is when the barrier trips, a fast goroutine
+can go around its loop and call Wait() again before the slower goroutines have woken up, and it
+gets counted towards the next round while the slow goroutines still belong to the previous one.
+nothing ties a waiting goroutine to the round it arrived in.
+
+to fix this, every round of the barrier is a generation. a goroutine remembers the generation it
+arrived in and waits until the barrier moves on to a new one, so once the barrier trips every
+goroutine of that round is released no matter how many goroutines arrive for the next round in
+the meantime.
+
+action is an optional callback that runs once every time the barrier trips, by the last goroutine
+to arrive and before any of the waiting goroutines are released. since everyone is still blocked
+it can safely look at the state all of them shared during the round.
+
+a barrier can also be broken, like java.util.concurrent.CyclicBarrier. if one of the goroutines
+stops taking part (ex. it panics, times out or gives up with WaitContext) the others would be
+waiting for it forever. instead the current generation is marked as broken, every goroutine
+waiting in it gets ErrBrokenBarrier and so does every goroutine that calls Wait() afterwards,
+until Reset() is called.
+*/
+
+// ErrBrokenBarrier is returned by Wait when the barrier was broken before it tripped.
+var ErrBrokenBarrier = errors.New("barrier: broken barrier")
+
+/*
+a round of the barrier. the goroutines waiting in a round keep a pointer to it,
+so the round can be broken without affecting the next one.
+*/
+type generation struct {
+	number int
+	broken bool
+}
 
 type Barrier struct {
 	size      int
 	waitCount int
+	gen       *generation
+	action    func(generation int)
 	cond      *sync.Cond
 }
 
-func NewBarrier(size int) *Barrier {
+// Option configures optional behaviour of a Barrier when it's created.
+type Option func(*Barrier)
+
+/*
+WithAction runs action every time the barrier trips, before any waiting goroutine is released.
+it's given the generation that just completed, starting from 0. it runs while the barrier is
+locked so it must not call any methods of the barrier. if it panics the barrier is broken.
+*/
+func WithAction(action func(generation int)) Option {
+	return func(b *Barrier) {
+		b.action = action
+	}
+}
+
+func NewBarrier(size int, opts ...Option) *Barrier {
 	condVar := sync.NewCond(&sync.Mutex{})
 	/*
 	   initializing the Barrier to have a current waitCount of 0
@@ -18,27 +76,147 @@ func NewBarrier(size int) *Barrier {
 	   allowed them to before we block them and synchronize their
 	   continued processing.
 	*/
-	return &Barrier{size, 0, condVar}
+	b := &Barrier{size: size, gen: &generation{}, cond: condVar}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
 }
 
-func (b *Barrier) Wait() {
+/*
+Wait blocks until size goroutines have called Wait and returns the arrival index of the current
+goroutine in this round. 0 is the first goroutine to arrive and size - 1 is the last one, which
+can be used to pick a single goroutine to do some work for the round.
+returns ErrBrokenBarrier if the barrier is or gets broken before it trips.
+*/
+func (b *Barrier) Wait() (int, error) {
+	return b.WaitContext(context.Background())
+}
+
+/*
+WaitContext is Wait that gives up once ctx is done. giving up breaks the barrier since the
+other goroutines can't trip it without us, and ctx.Err() is returned.
+*/
+func (b *Barrier) WaitContext(ctx context.Context) (int, error) {
 	b.cond.L.Lock()
+	defer b.cond.L.Unlock()
+
+	gen := b.gen
+	if gen.broken {
+		return 0, ErrBrokenBarrier
+	}
+	if err := ctx.Err(); err != nil {
+		b.breakBarrier()
+		return 0, err
+	}
+
 	/*
 	   incrementing the waitCount by 1 for the goroutine
 	   that calls .Wait(). That goroutine will be blocked
 	   until the Barrier's waitSize has reached size through
 	   other goroutines calling .Wait() and incrementing waitCount.
 	   Once the waitCount has reached the Barrier size value, we
-	   reset the Barrier count so it can be reused and call .Broadcast()
-	   so all goroutines that were blocked by the Barrier can continue processing.
+	   reset the Barrier count and move on to the next generation
+	   so it can be reused and call .Broadcast() so all goroutines
+	   that were blocked by the Barrier can continue processing.
 	*/
+	arrivalIndex := b.waitCount
 	b.waitCount += 1
 
 	if b.waitCount == b.size {
+		if b.action != nil {
+			b.runAction(gen.number)
+		}
 		b.waitCount = 0
+		b.gen = &generation{number: gen.number + 1}
 		b.cond.Broadcast()
-	} else {
+		return arrivalIndex, nil
+	}
+
+	// see syncutil.BroadcastOnDone for how the waiting goroutines get to see ctx being done
+	stop := syncutil.BroadcastOnDone(ctx, b.cond)
+	defer stop()
+
+	// only the generation changing means the barrier tripped for the round we arrived in
+	for gen == b.gen && !gen.broken {
+		if err := ctx.Err(); err != nil {
+			b.breakBarrier()
+			return arrivalIndex, err
+		}
 		b.cond.Wait()
 	}
+	if gen.broken {
+		return arrivalIndex, ErrBrokenBarrier
+	}
+	return arrivalIndex, nil
+}
+
+/*
+WaitTimeout is Wait that gives up after d. giving up breaks the barrier and
+context.DeadlineExceeded is returned.
+*/
+func (b *Barrier) WaitTimeout(d time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return b.WaitContext(ctx)
+}
+
+/*
+Break breaks the barrier. every goroutine waiting on it and every goroutine that calls Wait
+afterwards gets ErrBrokenBarrier until Reset is called.
+*/
+func (b *Barrier) Break() {
+	b.cond.L.Lock()
+	b.breakBarrier()
+	b.cond.L.Unlock()
+}
+
+/*
+Reset breaks the current round, so any goroutines waiting in it get ErrBrokenBarrier,
+and starts a new round that isn't broken.
+*/
+func (b *Barrier) Reset() {
+	b.cond.L.Lock()
+	b.breakBarrier()
+	b.waitCount = 0
+	b.gen = &generation{number: b.gen.number}
 	b.cond.L.Unlock()
 }
+
+// IsBroken reports whether the barrier is broken.
+func (b *Barrier) IsBroken() bool {
+	b.cond.L.Lock()
+	defer b.cond.L.Unlock()
+	return b.gen.broken
+}
+
+// Generation returns the number of times the barrier has tripped.
+func (b *Barrier) Generation() int {
+	b.cond.L.Lock()
+	defer b.cond.L.Unlock()
+	return b.gen.number
+}
+
+/*
+runs the action for the generation that just completed. if the action panics the generation
+never moves on, so the goroutines waiting in it would block forever and the ones calling Wait()
+afterwards would push waitCount past size and block forever too. the barrier is broken instead,
+same as CyclicBarrier does, and the panic carries on in the goroutine that tripped the barrier.
+must be called with the cond lock held.
+*/
+func (b *Barrier) runAction(generation int) {
+	completed := false
+	defer func() {
+		if !completed {
+			b.breakBarrier()
+		}
+	}()
+	b.action(generation)
+	completed = true
+}
+
+// must be called with the cond lock held.
+func (b *Barrier) breakBarrier() {
+	b.gen.broken = true
+	b.cond.Broadcast()
+}
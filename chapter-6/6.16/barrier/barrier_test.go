@@ -0,0 +1,54 @@
+package barrier
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestActionPanicBreaksBarrier(t *testing.T) {
+	b := NewBarrier(2, WithAction(func(generation int) {
+		panic("action failed")
+	}))
+
+	waitErr := make(chan error)
+	go func() {
+		_, err := b.Wait()
+		waitErr <- err
+	}()
+	for {
+		b.cond.L.Lock()
+		waiting := b.waitCount
+		b.cond.L.Unlock()
+		if waiting == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// the goroutine that trips the barrier runs the action, so it gets the panic
+	func() {
+		defer func() {
+			if r := recover(); r != "action failed" {
+				t.Fatalf("expected the action's panic, got %v", r)
+			}
+		}()
+		b.Wait()
+	}()
+
+	select {
+	case err := <-waitErr:
+		if !errors.Is(err, ErrBrokenBarrier) {
+			t.Fatalf("expected ErrBrokenBarrier for the waiting goroutine, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiting goroutine stuck after the action panicked")
+	}
+
+	if _, err := b.WaitTimeout(time.Second); !errors.Is(err, ErrBrokenBarrier) {
+		t.Fatalf("expected ErrBrokenBarrier for a later Wait, got %v", err)
+	}
+	if !b.IsBroken() {
+		t.Fatal("expected the barrier to be broken")
+	}
+}
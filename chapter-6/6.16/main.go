@@ -14,8 +14,16 @@ func main() {
 
 	/*
 	   setting barrier size to be matrixSize + 1
+	   the barrier trips twice per multiplication, once to start it and once when all
+	   rows are done. the barrier action runs before anyone is released from the second
+	   one, so it can print the results before main generates the next matrices.
 	*/
-	barr := barrier.NewBarrier(matrixSize + 1)
+	barr := barrier.NewBarrier(matrixSize+1, barrier.WithAction(func(generation int) {
+		if generation%2 == 1 {
+			fmt.Println("matrices after multiplication")
+			printMatrices(&matrixA, &matrixB, &result)
+		}
+	}))
 
 	/*
 	   starting the matrix multiplication. Even if all 3 rows have called rowMultiply,
@@ -39,15 +47,19 @@ func main() {
 			and calculate the matrix multiplication results for their
 			assigned rows.
 		*/
-		barr.Wait()
+		if _, err := barr.Wait(); err != nil {
+			fmt.Println("stopping multiplication:", err)
+			return
+		}
 		/*
 		   block again to wait for all rowMultiply goroutines to finish
 		   their processing and call .Wait(). Once that happens waitSize will
 		   reach the barrier size (4) and unblock all goroutines and reset the waitSize to 0
 		*/
-		barr.Wait()
-		fmt.Println("matrices after multiplication")
-		printMatrices(&matrixA, &matrixB, &result)
+		if _, err := barr.Wait(); err != nil {
+			fmt.Println("stopping multiplication:", err)
+			return
+		}
 	}
 }
 
@@ -77,8 +89,17 @@ func rowMultiply(
 		all goroutines have then allow them all to concurrently process and calculate
 		a matrix multiplication result for the current row
 	*/
+	/*
+		if this goroutine stops for any reason (ex. it panics) the other goroutines would be
+		blocked on the barrier forever waiting for it, so it breaks the barrier on the way out
+		and everyone else gets ErrBrokenBarrier from .Wait() instead.
+	*/
+	defer barr.Break()
+
 	for {
-		barr.Wait()
+		if _, err := barr.Wait(); err != nil {
+			return
+		}
 		for col := range matrixSize {
 			sum := 0
 			for i := range matrixSize {
@@ -91,7 +112,9 @@ func rowMultiply(
 		   will be blocked until all rowMultiply() goroutines have finished calculation for their row's
 		   respective matrix multiplications before moving on
 		*/
-		barr.Wait()
+		if _, err := barr.Wait(); err != nil {
+			return
+		}
 	}
 }
 
@@ -0,0 +1,191 @@
+package phaser
+
+import "sync"
+
+/*
+a Phaser is a reusable barrier like barrier.Barrier, but the number of goroutines taking part
+(parties) doesn't have to be fixed when it's created. it's based on java.util.concurrent.Phaser.
+
+- Register() adds a party, which also has to arrive before the current phase can end.
+- ArriveAndAwait() is barrier.Wait(), it arrives and blocks until every registered party has arrived.
+- Arrive() arrives without blocking, for goroutines that only need to let the others move on.
+- ArriveAndDeregister() arrives and stops taking part from the next phase on, without blocking.
+
+once every registered party has arrived, the phase counter moves on and the goroutines waiting
+for it are released. before that the onAdvance hook is called, which decides whether the phaser
+should be terminated. by default the phaser terminates when there are no parties left. once
+terminated all the methods return -1 straight away and waiting goroutines are released.
+*/
+
+type Phaser struct {
+	parties    int
+	arrived    int
+	phase      int
+	terminated bool
+	onAdvance  func(phase, parties int) bool
+	cond       *sync.Cond
+}
+
+// Option configures optional behaviour of a Phaser when it's created.
+type Option func(*Phaser)
+
+/*
+WithOnAdvance calls onAdvance every time a phase ends, before any waiting goroutine is released.
+it's given the phase that just ended and the number of registered parties, and terminates the
+phaser by returning true. it runs while the phaser is locked so it must not call its methods.
+if it panics the phaser is terminated.
+*/
+func WithOnAdvance(onAdvance func(phase, parties int) bool) Option {
+	return func(p *Phaser) {
+		p.onAdvance = onAdvance
+	}
+}
+
+func NewPhaser(parties int, opts ...Option) *Phaser {
+	p := &Phaser{
+		parties: parties,
+		cond:    sync.NewCond(&sync.Mutex{}),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Register adds a party to the phaser and returns the current phase, or -1 if it's terminated.
+func (p *Phaser) Register() int {
+	p.cond.L.Lock()
+	defer p.cond.L.Unlock()
+
+	if p.terminated {
+		return -1
+	}
+	p.parties++
+	return p.phase
+}
+
+// Arrive arrives at the current phase without waiting for the other parties and returns the phase arrived at.
+func (p *Phaser) Arrive() int {
+	p.cond.L.Lock()
+	defer p.cond.L.Unlock()
+	return p.arrive(false)
+}
+
+/*
+ArriveAndDeregister arrives at the current phase without waiting and stops taking part
+in the phases after it. returns the phase arrived at.
+*/
+func (p *Phaser) ArriveAndDeregister() int {
+	p.cond.L.Lock()
+	defer p.cond.L.Unlock()
+	return p.arrive(true)
+}
+
+/*
+ArriveAndAwait arrives at the current phase and blocks until every other party has arrived.
+returns the phase that was arrived at, or -1 if the phaser is or gets terminated.
+*/
+func (p *Phaser) ArriveAndAwait() int {
+	p.cond.L.Lock()
+	defer p.cond.L.Unlock()
+
+	phase := p.arrive(false)
+	if phase < 0 {
+		return phase
+	}
+	for phase == p.phase && !p.terminated {
+		p.cond.Wait()
+	}
+	if p.terminated {
+		return -1
+	}
+	return phase
+}
+
+// Phase returns the current phase, or -1 if the phaser is terminated.
+func (p *Phaser) Phase() int {
+	p.cond.L.Lock()
+	defer p.cond.L.Unlock()
+
+	if p.terminated {
+		return -1
+	}
+	return p.phase
+}
+
+// Parties returns the number of registered parties.
+func (p *Phaser) Parties() int {
+	p.cond.L.Lock()
+	defer p.cond.L.Unlock()
+	return p.parties
+}
+
+// Arrived returns the number of parties that have arrived at the current phase.
+func (p *Phaser) Arrived() int {
+	p.cond.L.Lock()
+	defer p.cond.L.Unlock()
+	return p.arrived
+}
+
+// IsTerminated reports whether the phaser is terminated.
+func (p *Phaser) IsTerminated() bool {
+	p.cond.L.Lock()
+	defer p.cond.L.Unlock()
+	return p.terminated
+}
+
+/*
+counts the arrival of a party and ends the phase if it was the last one.
+a party deregistering isn't counted as arrived, it just stops being waited for.
+must be called with the cond lock held.
+*/
+func (p *Phaser) arrive(deregister bool) int {
+	if p.terminated {
+		return -1
+	}
+	if p.arrived >= p.parties {
+		panic("phaser: more arrivals than registered parties")
+	}
+
+	phase := p.phase
+	if deregister {
+		p.parties--
+	} else {
+		p.arrived++
+	}
+	if p.arrived == p.parties {
+		p.advance()
+	}
+	return phase
+}
+
+// must be called with the cond lock held.
+func (p *Phaser) advance() {
+	if p.onAdvance != nil {
+		p.terminated = p.runOnAdvance()
+	} else {
+		p.terminated = p.parties == 0
+	}
+	p.arrived = 0
+	p.phase++
+	p.cond.Broadcast()
+}
+
+/*
+calls onAdvance for the phase that just ended. if it panics the phase never moves on, so the goroutines
+waiting in ArriveAndAwait would block forever. the phaser is terminated instead, the same as a panicking
+barrier action breaks the barrier, and the panic carries on in the goroutine that ended the phase.
+must be called with the cond lock held.
+*/
+func (p *Phaser) runOnAdvance() bool {
+	completed := false
+	defer func() {
+		if !completed {
+			p.terminated = true
+			p.cond.Broadcast()
+		}
+	}()
+	terminate := p.onAdvance(p.phase, p.parties)
+	completed = true
+	return terminate
+}
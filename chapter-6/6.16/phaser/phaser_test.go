@@ -0,0 +1,240 @@
+package phaser
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// waits until n parties have arrived at the current phase
+func waitForArrived(t *testing.T, p *Phaser, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Arrived() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d parties to arrive, got %d", n, p.Arrived())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// starts a goroutine calling ArriveAndAwait, the returned channel gets what it returned
+func awaitAsync(p *Phaser) chan int {
+	result := make(chan int, 1)
+	go func() { result <- p.ArriveAndAwait() }()
+	return result
+}
+
+func expectReturned(t *testing.T, name string, result chan int, want int) {
+	t.Helper()
+	select {
+	case got := <-result:
+		if got != want {
+			t.Fatalf("%s: expected %d, got %d", name, want, got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s: still blocked", name)
+	}
+}
+
+func TestPhaseCounter(t *testing.T) {
+	const parties, phases = 3, 5
+	p := NewPhaser(parties)
+
+	wg := sync.WaitGroup{}
+	for range parties {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for want := range phases {
+				if got := p.ArriveAndAwait(); got != want {
+					t.Errorf("expected to arrive at phase %d, got %d", want, got)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := p.Phase(); got != phases {
+		t.Fatalf("expected phase %d, got %d", phases, got)
+	}
+}
+
+func TestRegisterBetweenPhases(t *testing.T) {
+	p := NewPhaser(2)
+	first, second := awaitAsync(p), awaitAsync(p)
+	expectReturned(t, "first", first, 0)
+	expectReturned(t, "second", second, 0)
+
+	// the new party has to arrive before phase 1 can end
+	if phase := p.Register(); phase != 1 {
+		t.Fatalf("expected to register in phase 1, got %d", phase)
+	}
+	first, second = awaitAsync(p), awaitAsync(p)
+	waitForArrived(t, p, 2)
+	if p.Phase() != 1 {
+		t.Fatal("phase ended before the registered party arrived")
+	}
+
+	third := awaitAsync(p)
+	expectReturned(t, "first", first, 1)
+	expectReturned(t, "second", second, 1)
+	expectReturned(t, "third", third, 1)
+	if p.Parties() != 3 || p.Phase() != 2 {
+		t.Fatalf("expected 3 parties in phase 2, got %d in %d", p.Parties(), p.Phase())
+	}
+}
+
+func TestArriveDoesNotBlock(t *testing.T) {
+	p := NewPhaser(2)
+	if phase := p.Arrive(); phase != 0 {
+		t.Fatalf("expected to arrive at phase 0, got %d", phase)
+	}
+	if p.Arrived() != 1 || p.Phase() != 0 {
+		t.Fatal("phase ended before every party arrived")
+	}
+
+	// the other party is the last one, so it doesn't wait either
+	expectReturned(t, "last party", awaitAsync(p), 0)
+	if p.Phase() != 1 || p.Arrived() != 0 {
+		t.Fatalf("expected phase 1 with nobody arrived, got phase %d with %d arrived", p.Phase(), p.Arrived())
+	}
+}
+
+func TestArriveAndDeregisterEndsPhase(t *testing.T) {
+	p := NewPhaser(3)
+	waiter := awaitAsync(p)
+	p.Arrive()
+	waitForArrived(t, p, 2)
+
+	// the party leaving was the only one the phase was still waiting for
+	if phase := p.ArriveAndDeregister(); phase != 0 {
+		t.Fatalf("expected to arrive at phase 0, got %d", phase)
+	}
+	expectReturned(t, "waiter", waiter, 0)
+	if p.Parties() != 2 || p.IsTerminated() {
+		t.Fatalf("expected 2 parties left and not terminated, got %d", p.Parties())
+	}
+
+	// the next phase only waits for the 2 parties left
+	waiter = awaitAsync(p)
+	expectReturned(t, "last party", awaitAsync(p), 1)
+	expectReturned(t, "waiter", waiter, 1)
+}
+
+func TestTerminatedByOnAdvance(t *testing.T) {
+	const parties = 3
+	var ended []int
+	p := NewPhaser(parties, WithOnAdvance(func(phase, registered int) bool {
+		ended = append(ended, phase)
+		return phase == 2
+	}))
+
+	results := make([][]int, parties)
+	wg := sync.WaitGroup{}
+	for i := range parties {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				phase := p.ArriveAndAwait()
+				results[i] = append(results[i], phase)
+				if phase < 0 {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// every goroutine saw phases 0 and 1, and the waiters of phase 2 were released with -1
+	for i, phases := range results {
+		if len(phases) != 3 || phases[0] != 0 || phases[1] != 1 || phases[2] != -1 {
+			t.Fatalf("goroutine %d saw phases %v", i, phases)
+		}
+	}
+	if len(ended) != 3 {
+		t.Fatalf("expected onAdvance for phases 0 to 2, got %v", ended)
+	}
+	expectTerminated(t, p)
+}
+
+func TestTerminatedByLastPartyLeaving(t *testing.T) {
+	p := NewPhaser(2)
+	p.ArriveAndDeregister()
+	if p.IsTerminated() {
+		t.Fatal("terminated with a party left")
+	}
+	p.ArriveAndDeregister()
+	expectTerminated(t, p)
+}
+
+// once terminated everything returns -1 straight away
+func expectTerminated(t *testing.T, p *Phaser) {
+	t.Helper()
+	if !p.IsTerminated() || p.Phase() != -1 {
+		t.Fatal("expected the phaser to be terminated")
+	}
+	if p.Register() != -1 || p.Arrive() != -1 || p.ArriveAndDeregister() != -1 {
+		t.Fatal("expected -1 from a terminated phaser")
+	}
+	expectReturned(t, "ArriveAndAwait", awaitAsync(p), -1)
+}
+
+func TestOnAdvancePanicTerminates(t *testing.T) {
+	p := NewPhaser(2, WithOnAdvance(func(phase, parties int) bool {
+		panic("onAdvance failed")
+	}))
+	waiter := awaitAsync(p)
+	waitForArrived(t, p, 1)
+
+	// the goroutine that ends the phase runs onAdvance, so it gets the panic
+	func() {
+		defer func() {
+			if r := recover(); r != "onAdvance failed" {
+				t.Fatalf("expected the onAdvance panic, got %v", r)
+			}
+		}()
+		p.Arrive()
+	}()
+
+	expectReturned(t, "waiter", waiter, -1)
+	expectTerminated(t, p)
+}
+
+/*
+workers join and leave while the others keep going through phases. a worker registers the next
+one before arriving, so the phase it's registered in can't end before the new worker arrives.
+every worker has to see the phases one after the other, from the one it registered in, and the
+phaser terminates once the last of them leaves.
+*/
+func TestDynamicParties(t *testing.T) {
+	p := NewPhaser(1)
+	wg := sync.WaitGroup{}
+
+	var start func(phase, depth int)
+	start = func(phase, depth int) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer p.ArriveAndDeregister()
+			for n := range 10 {
+				if n == 3 && depth < 7 {
+					start(p.Register(), depth+1)
+				}
+				if got := p.ArriveAndAwait(); got != phase {
+					t.Errorf("expected phase %d, got %d", phase, got)
+					return
+				}
+				phase++
+			}
+		}()
+	}
+	start(p.Register(), 0)
+	p.ArriveAndDeregister()
+	wg.Wait()
+
+	if !p.IsTerminated() {
+		t.Fatal("expected the phaser to terminate once every party left")
+	}
+}
@@ -0,0 +1,165 @@
+package errgrp
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"6.3.2/waitgrp"
+)
+
+/*
+the fork/join examples (ex. 10.2, 10.5) pair wg.Add(1) with a goroutine and throw away any errors,
+or panic. Group does the same fork/join on top of our WaitGrp, but also collects errors, like
+golang.org/x/sync/errgroup:
+- Go(f) runs f in a new goroutine, Wait() blocks until all of them have returned and returns
+  the first error any of them returned.
+- every f is given a context that's cancelled as soon as one of them fails, so the others
+  can stop early instead of doing work nobody will use.
+- SetLimit(n) limits the number of goroutines running at the same time, Go blocks until one
+  of them finishes.
+- a panic in one of the goroutines would crash the program from a goroutine that has nothing to do
+  with the caller, so it's recovered and panics again in the goroutine calling Wait(), wrapped in a
+  PanicError or PanicValue that keeps the recovered value and the stack where it happened.
+*/
+
+/*
+PanicError is what Wait panics with when a goroutine of the group panicked with an error.
+Unwrap returns that error, so the caller can recover() and use errors.As/errors.Is on it.
+*/
+type PanicError struct {
+	Recovered error
+	Stack     []byte
+}
+
+func (p PanicError) Error() string {
+	return fmt.Sprintf("errgrp: goroutine panicked: %v\n\n%s", p.Recovered, p.Stack)
+}
+
+func (p PanicError) Unwrap() error {
+	return p.Recovered
+}
+
+// PanicValue is what Wait panics with when a goroutine of the group panicked with a value that isn't an error.
+type PanicValue struct {
+	Recovered any
+	Stack     []byte
+}
+
+func (p PanicValue) String() string {
+	return fmt.Sprintf("errgrp: goroutine panicked: %v\n\n%s", p.Recovered, p.Stack)
+}
+
+type Group struct {
+	wg     *waitgrp.WaitGrp
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	// sem has a slot for each goroutine allowed to run at the same time, nil if there's no limit
+	sem chan struct{}
+
+	mutex      sync.Mutex
+	err        error
+	panicValue any
+}
+
+/*
+NewGroup creates a Group whose goroutines get a context derived from ctx,
+which is cancelled when one of them fails or when Wait returns.
+*/
+func NewGroup(ctx context.Context) *Group {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &Group{
+		wg:     waitgrp.NewWaitGrp(),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+/*
+SetLimit limits the number of goroutines started by Go running at the same time to n.
+a negative n means no limit. it can't be changed while goroutines of the group are running.
+*/
+func (g *Group) SetLimit(n int) {
+	if n < 0 {
+		g.sem = nil
+		return
+	}
+	if len(g.sem) != 0 {
+		panic(fmt.Errorf("errgrp: modify limit while %d goroutines in the group are still active", len(g.sem)))
+	}
+	g.sem = make(chan struct{}, n)
+}
+
+/*
+Go runs f in a new goroutine. if a limit was set it blocks until there's room for one more
+goroutine. the first error returned (or panic) cancels the context given to every f.
+*/
+func (g *Group) Go(f func(ctx context.Context) error) {
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+
+	g.wg.Add(1)
+	go func() {
+		defer g.done()
+		defer func() {
+			if r := recover(); r != nil {
+				g.fail(newPanic(r, debug.Stack()))
+			}
+		}()
+
+		if err := f(g.ctx); err != nil {
+			g.fail(err, nil)
+		}
+	}()
+}
+
+/*
+Wait blocks until every goroutine started with Go has returned and returns the first error.
+if one of them panicked, Wait panics with a PanicError or PanicValue holding the recovered value.
+*/
+func (g *Group) Wait() error {
+	g.wg.Wait()
+
+	g.mutex.Lock()
+	err, panicValue := g.err, g.panicValue
+	g.mutex.Unlock()
+
+	g.cancel(err)
+	if panicValue != nil {
+		panic(panicValue)
+	}
+	return err
+}
+
+// wraps a recovered value and its stack. returns the error to cancel the group with and the value for Wait to panic with.
+func newPanic(recovered any, stack []byte) (error, any) {
+	if err, ok := recovered.(error); ok {
+		p := PanicError{Recovered: err, Stack: stack}
+		return p, p
+	}
+	return fmt.Errorf("errgrp: goroutine panicked: %v", recovered), PanicValue{Recovered: recovered, Stack: stack}
+}
+
+// records the first error or panic and cancels the context of the group.
+func (g *Group) fail(err error, panicValue any) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.err == nil {
+		g.err = err
+		g.cancel(err)
+	}
+	if panicValue != nil && g.panicValue == nil {
+		g.panicValue = panicValue
+	}
+}
+
+func (g *Group) done() {
+	if g.sem != nil {
+		<-g.sem
+	}
+	g.wg.Done()
+}
@@ -0,0 +1,129 @@
+package errgrp
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type customError struct{ code int }
+
+func (e *customError) Error() string { return "custom error" }
+
+// runs the group's Wait and returns what it panicked with
+func recoverWait(g *Group) (recovered any) {
+	defer func() { recovered = recover() }()
+	g.Wait()
+	return nil
+}
+
+func TestPanicWithErrorKeepsTheError(t *testing.T) {
+	g := NewGroup(context.Background())
+	g.Go(func(ctx context.Context) error {
+		panic(&customError{code: 42})
+	})
+
+	r := recoverWait(g)
+	p, ok := r.(PanicError)
+	if !ok {
+		t.Fatalf("expected a PanicError, got %T", r)
+	}
+	var custom *customError
+	if !errors.As(p, &custom) || custom.code != 42 {
+		t.Fatalf("expected the original error to be recoverable, got %v", p.Recovered)
+	}
+	if len(p.Stack) == 0 {
+		t.Fatal("expected the stack of the panic")
+	}
+}
+
+func TestPanicWithValueKeepsTheValue(t *testing.T) {
+	g := NewGroup(context.Background())
+	g.Go(func(ctx context.Context) error {
+		panic(42)
+	})
+
+	r := recoverWait(g)
+	p, ok := r.(PanicValue)
+	if !ok || p.Recovered != 42 {
+		t.Fatalf("expected a PanicValue with 42, got %#v", r)
+	}
+}
+
+func TestFirstErrorCancelsTheOthers(t *testing.T) {
+	g := NewGroup(context.Background())
+	failure := errors.New("failed")
+	g.Go(func(ctx context.Context) error {
+		return failure
+	})
+	g.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		return context.Cause(ctx)
+	})
+
+	if err := g.Wait(); !errors.Is(err, failure) {
+		t.Fatalf("expected the first error, got %v", err)
+	}
+}
+
+func TestSetLimitBoundsRunningGoroutines(t *testing.T) {
+	const limit = 3
+	g := NewGroup(context.Background())
+	g.SetLimit(limit)
+
+	var running, maxRunning atomic.Int32
+	for range 30 {
+		g.Go(func(ctx context.Context) error {
+			n := running.Add(1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if got := maxRunning.Load(); got > limit {
+		t.Fatalf("%d goroutines ran at once with a limit of %d", got, limit)
+	}
+}
+
+func TestGoBlocksAtTheLimit(t *testing.T) {
+	g := NewGroup(context.Background())
+	g.SetLimit(1)
+
+	release := make(chan struct{})
+	g.Go(func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+
+	started := make(chan struct{})
+	go func() {
+		g.Go(func(ctx context.Context) error { return nil })
+		close(started)
+	}()
+	select {
+	case <-started:
+		t.Fatal("Go didn't block with the limit reached")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Go still blocked after a goroutine finished")
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,3 +1,7 @@
 module 6.3.2
 
 go 1.22.2
+
+require syncutil v0.0.0
+
+replace syncutil => ../../../chapter-5/syncutil
@@ -0,0 +1,160 @@
+package latch
+
+import (
+	"context"
+	"sync"
+
+	"syncutil"
+)
+
+/*
+one-shot signals that are usually done with a channel that gets closed or a WaitGrp
+(ex. prevSignal/currentSignal in 10.3 or the quit channels in chapter 9), built with
+a condition variable the same way as WaitGrp.
+
+- CountDownLatch starts at a count and releases every goroutine waiting on it once
+  the count has been counted down to zero. it can't be reused after that.
+- Event is a flag that goroutines can wait to be set. unlike closing a channel it
+  can be cleared again and reused.
+*/
+
+type CountDownLatch struct {
+	count int
+	cond  *sync.Cond
+}
+
+/*
+NewCountDownLatch creates a latch that opens after count calls to CountDown. a negative count
+panics, since the latch would be open straight away and CountDown would keep decrementing it.
+*/
+func NewCountDownLatch(count int) *CountDownLatch {
+	if count < 0 {
+		panic("latch: negative CountDownLatch count")
+	}
+	return &CountDownLatch{
+		count: count,
+		cond:  sync.NewCond(&sync.Mutex{}),
+	}
+}
+
+// CountDown decrements the count, releasing the waiting goroutines once it reaches zero.
+func (l *CountDownLatch) CountDown() {
+	l.cond.L.Lock()
+	defer l.cond.L.Unlock()
+
+	// counting down an open latch does nothing, same as closing the channel only once
+	if l.count == 0 {
+		return
+	}
+	l.count--
+	if l.count == 0 {
+		l.cond.Broadcast()
+	}
+}
+
+// Await blocks until the count reaches zero.
+func (l *CountDownLatch) Await() {
+	l.cond.L.Lock()
+	for l.count > 0 {
+		l.cond.Wait()
+	}
+	l.cond.L.Unlock()
+}
+
+/*
+AwaitContext blocks until the count reaches zero or ctx is done,
+in which case ctx.Err() is returned.
+*/
+func (l *CountDownLatch) AwaitContext(ctx context.Context) error {
+	l.cond.L.Lock()
+	defer l.cond.L.Unlock()
+
+	stop := syncutil.BroadcastOnDone(ctx, l.cond)
+	defer stop()
+
+	for l.count > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		l.cond.Wait()
+	}
+	return nil
+}
+
+// Count returns the current count.
+func (l *CountDownLatch) Count() int {
+	l.cond.L.Lock()
+	defer l.cond.L.Unlock()
+	return l.count
+}
+
+/*
+set is the flag itself. generation is incremented every time the event is set, so a goroutine
+that was waiting is still released if the event gets cleared again before it wakes up.
+*/
+type Event struct {
+	set        bool
+	generation int
+	cond       *sync.Cond
+}
+
+func NewEvent() *Event {
+	return &Event{
+		cond: sync.NewCond(&sync.Mutex{}),
+	}
+}
+
+// Set sets the event and releases every goroutine waiting for it.
+func (e *Event) Set() {
+	e.cond.L.Lock()
+	defer e.cond.L.Unlock()
+
+	if e.set {
+		return
+	}
+	e.set = true
+	e.generation++
+	e.cond.Broadcast()
+}
+
+// Clear resets the event so goroutines calling Wait block again until the next Set.
+func (e *Event) Clear() {
+	e.cond.L.Lock()
+	e.set = false
+	e.cond.L.Unlock()
+}
+
+// Wait blocks until the event is set.
+func (e *Event) Wait() {
+	e.cond.L.Lock()
+	generation := e.generation
+	for !e.set && generation == e.generation {
+		e.cond.Wait()
+	}
+	e.cond.L.Unlock()
+}
+
+// WaitContext blocks until the event is set or ctx is done, in which case ctx.Err() is returned.
+func (e *Event) WaitContext(ctx context.Context) error {
+	e.cond.L.Lock()
+	defer e.cond.L.Unlock()
+
+	stop := syncutil.BroadcastOnDone(ctx, e.cond)
+	defer stop()
+
+	generation := e.generation
+	for !e.set && generation == e.generation {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		e.cond.Wait()
+	}
+	return nil
+}
+
+// IsSet reports whether the event is set.
+func (e *Event) IsSet() bool {
+	e.cond.L.Lock()
+	defer e.cond.L.Unlock()
+	return e.set
+}
@@ -0,0 +1,90 @@
+package latch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCountDownLatchReleasesWaitersAtZero(t *testing.T) {
+	const count = 10
+	l := NewCountDownLatch(count)
+	var countedDown atomic.Int32
+
+	waiters := sync.WaitGroup{}
+	for range 5 {
+		waiters.Add(1)
+		go func() {
+			defer waiters.Done()
+			l.Await()
+			if n := countedDown.Load(); n != count {
+				t.Errorf("released after %d of %d count downs", n, count)
+			}
+		}()
+	}
+
+	for range count {
+		go func() {
+			countedDown.Add(1)
+			l.CountDown()
+		}()
+	}
+	waiters.Wait()
+
+	// counting down an open latch does nothing and Await returns straight away
+	l.CountDown()
+	if l.Count() != 0 {
+		t.Fatalf("expected count 0, got %d", l.Count())
+	}
+	l.Await()
+}
+
+func TestCountDownLatchAwaitContext(t *testing.T) {
+	l := NewCountDownLatch(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.AwaitContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+
+	l.CountDown()
+	if err := l.AwaitContext(context.Background()); err != nil {
+		t.Fatalf("expected the open latch to return nil, got %v", err)
+	}
+}
+
+func TestNegativeCountPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("NewCountDownLatch(-1) didn't panic")
+		}
+	}()
+	NewCountDownLatch(-1)
+}
+
+func TestEventSetReleasesWaitersAndClearResets(t *testing.T) {
+	e := NewEvent()
+	waiters := sync.WaitGroup{}
+	for range 5 {
+		waiters.Add(1)
+		go func() {
+			defer waiters.Done()
+			e.Wait()
+		}()
+	}
+	e.Set()
+	waiters.Wait()
+	if !e.IsSet() {
+		t.Fatal("expected the event to be set")
+	}
+
+	e.Clear()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := e.WaitContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a cleared event to block, got %v", err)
+	}
+}
@@ -1,13 +1,29 @@
 package waitgrp
 
-import "sync"
+import (
+	"context"
+	"sync"
+	"time"
+
+	"syncutil"
+)
 
 /*
 new implementation of WaitGrp that allows for adding to
 the wait counter after it has been initialized.
+
+like go's sync.WaitGroup, it panics when it's misused instead of silently misbehaving:
+  - if the counter goes below zero (ex. one Done() too many). otherwise the counter never hits
+    exactly 0 again, so the Broadcast() would be skipped, or Wait() returns before the work is done.
+  - if Add() is called with a positive delta while goroutines blocked in Wait() are still being
+    released. those goroutines would wake up to a counter that's above 0 again and go back to
+    waiting for work that isn't theirs, so the WaitGrp can only be reused once every Wait() returned.
+
+waiters is the number of goroutines blocked in Wait() that haven't returned yet.
 */
 type WaitGrp struct {
 	groupSize int
+	waiters   int
 	cond      *sync.Cond
 }
 
@@ -19,27 +35,74 @@ func NewWaitGrp() *WaitGrp {
 
 func (wg *WaitGrp) Add(delta int) {
 	wg.cond.L.Lock()
+	if delta > 0 && wg.groupSize == 0 && wg.waiters > 0 {
+		wg.cond.L.Unlock()
+		panic("waitgrp: WaitGrp is reused before previous Wait has returned")
+	}
 	wg.groupSize += delta
+	if wg.groupSize < 0 {
+		wg.cond.L.Unlock()
+		panic("waitgrp: negative WaitGrp counter")
+	}
+	if wg.groupSize == 0 {
+		wg.cond.Broadcast()
+	}
 	wg.cond.L.Unlock()
 }
 
 func (wg *WaitGrp) Wait() {
 	wg.cond.L.Lock()
 
-	for wg.groupSize > 0 {
-		wg.cond.Wait()
+	if wg.groupSize > 0 {
+		wg.waiters++
+		for wg.groupSize > 0 {
+			wg.cond.Wait()
+		}
+		wg.waiters--
 	}
 
 	wg.cond.L.Unlock()
 }
 
-func (wg *WaitGrp) Done() {
+/*
+WaitContext is Wait that gives up once ctx is done, returning ctx.Err().
+the waiting goroutines are woken up once ctx is done by syncutil.BroadcastOnDone.
+*/
+func (wg *WaitGrp) WaitContext(ctx context.Context) error {
 	wg.cond.L.Lock()
-	wg.groupSize -= 1
+	defer wg.cond.L.Unlock()
+
 	if wg.groupSize == 0 {
-		wg.cond.Broadcast()
+		return nil
 	}
-	wg.cond.L.Unlock()
+
+	stop := syncutil.BroadcastOnDone(ctx, wg.cond)
+	defer stop()
+
+	wg.waiters++
+	defer func() { wg.waiters-- }()
+	for wg.groupSize > 0 {
+		/*
+		   the counter is checked first so if the last Done() and the deadline
+		   race each other, the WaitGrp finishing wins.
+		*/
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		wg.cond.Wait()
+	}
+	return nil
+}
+
+// WaitTimeout waits at most d for the counter to reach zero and reports whether it did.
+func (wg *WaitGrp) WaitTimeout(d time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return wg.WaitContext(ctx) == nil
+}
+
+func (wg *WaitGrp) Done() {
+	wg.Add(-1)
 }
 
 /*
@@ -0,0 +1,173 @@
+package waitgrp
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func expectPanic(t *testing.T, name string, f func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("%s didn't panic", name)
+		}
+	}()
+	f()
+}
+
+// polls the number of goroutines blocked in Wait until it's n
+func waitForWaiters(t *testing.T, wg *WaitGrp, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		wg.cond.L.Lock()
+		waiters := wg.waiters
+		wg.cond.L.Unlock()
+		if waiters == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d waiters, got %d", n, waiters)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWaitReturnsOnceAllDone(t *testing.T) {
+	wg := NewWaitGrp()
+	var done atomic.Int32
+	const workers = 20
+
+	wg.Add(workers / 2)
+	for i := range workers {
+		// half the work is added up front, the rest after the group has started
+		if i >= workers/2 {
+			wg.Add(1)
+		}
+		go func() {
+			done.Add(1)
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+
+	if n := done.Load(); n != workers {
+		t.Fatalf("Wait returned after %d of %d Done calls", n, workers)
+	}
+	if !wg.TryWait() {
+		t.Fatal("expected TryWait to succeed once the counter is zero")
+	}
+}
+
+func TestWaitOnEmptyGroupReturnsStraightAway(t *testing.T) {
+	wg := NewWaitGrp()
+	wg.Wait()
+	if !wg.TryWait() {
+		t.Fatal("expected TryWait to succeed on an empty group")
+	}
+}
+
+func TestNegativeCounterPanics(t *testing.T) {
+	wg := NewWaitGrp()
+	wg.Add(1)
+	wg.Done()
+	expectPanic(t, "an extra Done", wg.Done)
+	expectPanic(t, "Add(-1) on an empty group", func() { NewWaitGrp().Add(-1) })
+}
+
+func TestReuseBeforeWaitReturnsPanics(t *testing.T) {
+	wg := NewWaitGrp()
+	wg.Add(1)
+	go wg.Wait()
+	waitForWaiters(t, wg, 1)
+
+	/*
+	   the counter reaching zero and the waiter returning happen at different times. the state in
+	   between is set up directly, since otherwise the waiter could return before Add is called.
+	*/
+	wg.cond.L.Lock()
+	wg.groupSize = 0
+	wg.cond.L.Unlock()
+	expectPanic(t, "Add while a Wait is being released", func() { wg.Add(1) })
+
+	wg.cond.L.Lock()
+	wg.cond.Broadcast()
+	wg.cond.L.Unlock()
+	waitForWaiters(t, wg, 0)
+
+	// once every Wait has returned the group can be reused
+	wg.Add(1)
+	wg.Done()
+	wg.Wait()
+}
+
+func TestWaitContextGivesUpWhenDone(t *testing.T) {
+	wg := NewWaitGrp()
+	wg.Add(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := wg.WaitContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected Canceled, got %v", err)
+	}
+	if wg.WaitTimeout(10 * time.Millisecond) {
+		t.Fatal("WaitTimeout succeeded while the counter is above zero")
+	}
+
+	wg.Done()
+	if !wg.WaitTimeout(time.Second) {
+		t.Fatal("WaitTimeout failed once the counter is zero")
+	}
+}
+
+/*
+the last Done() and the deadline race each other. WaitTimeout may report either, but it must
+never report success before Done() was called, and a WaitTimeout that gave up must not be left
+counted as a waiter, otherwise reusing the group with Add() would panic.
+*/
+func TestWaitTimeoutRacingDone(t *testing.T) {
+	wg := NewWaitGrp()
+	for i := range 500 {
+		var called atomic.Bool
+		wg.Add(1)
+		go func() {
+			time.Sleep(time.Duration(i%100) * time.Microsecond)
+			called.Store(true)
+			wg.Done()
+		}()
+
+		if wg.WaitTimeout(50*time.Microsecond) && !called.Load() {
+			t.Fatal("WaitTimeout returned true before Done was called")
+		}
+		wg.Wait()
+		waitForWaiters(t, wg, 0)
+	}
+}
+
+func TestWaitContextDoesNotLeakGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	wg := NewWaitGrp()
+	wg.Add(1)
+	for range 100 {
+		wg.WaitTimeout(100 * time.Microsecond)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go cancel()
+		wg.WaitContext(ctx)
+	}
+	wg.Done()
+
+	// the functions registered with AfterFunc may still be finishing their broadcast
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines before, %d after", before, runtime.NumGoroutine())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
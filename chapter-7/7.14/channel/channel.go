@@ -2,11 +2,25 @@ package channel
 
 import (
 	"container/list"
+	"errors"
 	"sync"
 
 	"7.14/semaphore"
 )
 
+/*
+Close() works the same way as closing a built-in chan:
+- Send() on a closed channel panics, including senders that were blocked when it got closed.
+- Receive() keeps returning the buffered messages after the channel is closed, and once
+the buffer is empty returns the zero value and false straight away instead of blocking.
+receivers that were blocked when it got closed are woken up and do the same.
+- closing a channel that's already closed panics.
+
+the goroutines blocked in Send() or Receive() are waiting on a semaphore, and we don't know how
+many of them there are. so Close() releases one extra permit on each semaphore, and every goroutine
+that gets that permit and finds the channel closed releases it again before returning, which wakes
+up the next blocked goroutine until all of them have seen that the channel is closed.
+*/
 type Channel[M any] struct {
 	/*
 	   using 2 semaphores to block for separate senarios.
@@ -21,17 +35,37 @@ type Channel[M any] struct {
 	mutex sync.Mutex
 
 	buffer *list.List // standard library queue implementation
+
+	closed bool
+
+	capacity int // the capacity the channel was created with, only used by Cap()
+
+	policy  Overflow // what Send does when the buffer is full, see overflow.go
+	dropped int      // messages not let through by policy
 }
 
-func NewChannel[M any](capacity int) *Channel[M] {
+// ErrClosed is returned by ReceiveContext once the channel is closed and there are no buffered messages left.
+var ErrClosed = errors.New("channel: receive from closed channel")
+
+func NewChannel[M any](capacity int, opts ...Option) *Channel[M] {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
 	return &Channel[M]{
+		capacity:          capacity,
+		policy:            cfg.overflow,
 		capacitySemaphore: semaphore.NewSemaphore(capacity),
 		sizeSemaphore:     semaphore.NewSemaphore(0),
 		buffer:            list.New(),
 	}
 }
 
-func (c *Channel[M]) Send(message M) {
+/*
+Send sends message, blocking while the buffer is full unless the channel was created with
+a different Overflow policy. returns ErrFull if the buffer is full and the policy is Error.
+*/
+func (c *Channel[M]) Send(message M) error {
 	/*
 	   when attempting to send a message through the channel, we
 	   attempt to acquire a permit on the capacitySemaphore.
@@ -43,9 +77,26 @@ func (c *Channel[M]) Send(message M) {
 	   will be blocked until other goroutines calls .Release() to increment
 	   the permits in the capacitySemaphore to allow .Send() to acquire permits.
 	*/
-	c.capacitySemaphore.Acquire()
+	if c.policy != Block {
+		if !c.capacitySemaphore.TryAcquire() {
+			return c.overflow(message)
+		}
+	} else {
+		c.capacitySemaphore.Acquire()
+	}
+	c.push(message)
+	return nil
+}
 
+// pushes the message once the capacity permit for it was acquired.
+func (c *Channel[M]) push(message M) {
 	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		// pass the permit on so the next blocked sender also finds out the channel is closed
+		c.capacitySemaphore.Release()
+		panic("send on closed channel")
+	}
 	c.buffer.PushBack(message)
 	c.mutex.Unlock()
 
@@ -60,7 +111,11 @@ func (c *Channel[M]) Send(message M) {
 	c.sizeSemaphore.Release()
 }
 
-func (c *Channel[M]) Receive() M {
+/*
+Receive returns the next message and true, or the zero value and false
+once the channel is closed and there are no buffered messages left.
+*/
+func (c *Channel[M]) Receive() (M, bool) {
 	/*
 	   increments the capacitySemaphore's permit count by 1 since we're consuming a message
 	   off the buffer. this will allow another goroutine to send 1 more message to the buffer.
@@ -74,10 +129,41 @@ func (c *Channel[M]) Receive() M {
 	   counter by 1.
 	*/
 	c.sizeSemaphore.Acquire()
+	return c.take()
+}
 
+// takes the next message once the size permit for it was acquired.
+func (c *Channel[M]) take() (M, bool) {
 	c.mutex.Lock()
+	/*
+	   every buffered message has a permit of its own, so an empty buffer means we got
+	   the extra permit released by Close(). it's passed on to the next blocked receiver.
+	*/
+	if c.buffer.Len() == 0 {
+		c.mutex.Unlock()
+		c.sizeSemaphore.Release()
+		var zero M
+		return zero, false
+	}
 	v := c.buffer.Remove(c.buffer.Front()).(M)
 	c.mutex.Unlock()
 
-	return v
+	return v, true
+}
+
+/*
+Close marks the channel as closed so no more messages can be sent, and wakes up
+the blocked goroutines so receivers can return and senders can panic.
+*/
+func (c *Channel[M]) Close() {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		panic("close of closed channel")
+	}
+	c.closed = true
+	c.mutex.Unlock()
+
+	c.sizeSemaphore.Release()
+	c.capacitySemaphore.Release()
 }
@@ -0,0 +1,139 @@
+package channel
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+Close is supposed to work the same way as closing a built-in chan, so every scenario is run
+against a built-in chan first and the Channel has to give the same results.
+*/
+
+type testChannel interface {
+	Send(message int) error
+	Receive() (int, bool)
+	Close()
+}
+
+// adapts a built-in chan to testChannel
+type builtinChan chan int
+
+func (c builtinChan) Send(message int) error {
+	c <- message
+	return nil
+}
+
+func (c builtinChan) Receive() (int, bool) {
+	v, ok := <-c
+	return v, ok
+}
+
+func (c builtinChan) Close() { close(c) }
+
+// how long to give goroutines to block before closing the channel under them
+const blockDelay = 20 * time.Millisecond
+
+// runs f and returns "panic" if it panicked, or "" if it didn't
+func panicOf(f func()) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = "panic"
+		}
+	}()
+	f()
+	return ""
+}
+
+func compareWithBuiltin(t *testing.T, capacity int, scenario func(c testChannel) []string) {
+	t.Helper()
+	want := scenario(make(builtinChan, capacity))
+	got := scenario(NewChannel[int](capacity))
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("built-in chan gave %v, Channel gave %v", want, got)
+	}
+}
+
+func TestCloseDrainsBufferedMessages(t *testing.T) {
+	compareWithBuiltin(t, 3, func(c testChannel) []string {
+		c.Send(1)
+		c.Send(2)
+		c.Close()
+		var results []string
+		for range 4 {
+			v, ok := c.Receive()
+			results = append(results, fmt.Sprint(v, ok))
+		}
+		return results
+	})
+}
+
+func TestCloseWakesBlockedReceivers(t *testing.T) {
+	for _, capacity := range []int{0, 2} {
+		compareWithBuiltin(t, capacity, func(c testChannel) []string {
+			results := make([]string, 3)
+			wg := sync.WaitGroup{}
+			for i := range results {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					v, ok := c.Receive()
+					results[i] = fmt.Sprint(v, ok)
+				}()
+			}
+			time.Sleep(blockDelay)
+			c.Close()
+			wg.Wait()
+			return results
+		})
+	}
+}
+
+func TestSendOnClosedPanics(t *testing.T) {
+	compareWithBuiltin(t, 1, func(c testChannel) []string {
+		c.Close()
+		return []string{panicOf(func() { c.Send(1) })}
+	})
+}
+
+/*
+a built-in chan panics in senders that are blocked when it gets closed too, but the race detector
+reports closing a chan while a send on it is in progress, so this one isn't compared with a chan.
+*/
+func TestCloseWakesBlockedSendersWithPanic(t *testing.T) {
+	for _, capacity := range []int{0, 1} {
+		c := NewChannel[int](capacity)
+		// fill the buffer so the senders below block
+		for range capacity {
+			c.Send(0)
+		}
+		results := make([]string, 3)
+		wg := sync.WaitGroup{}
+		for i := range results {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] = panicOf(func() { c.Send(1) })
+			}()
+		}
+		time.Sleep(blockDelay)
+		c.Close()
+		wg.Wait()
+
+		for i, r := range results {
+			if r != "panic" {
+				t.Fatalf("capacity %d: blocked sender %d didn't panic", capacity, i)
+			}
+		}
+	}
+}
+
+func TestDoubleClosePanics(t *testing.T) {
+	compareWithBuiltin(t, 0, func(c testChannel) []string {
+		c.Close()
+		return []string{panicOf(c.Close)}
+	})
+}
@@ -0,0 +1,148 @@
+package channel
+
+import (
+	"context"
+	"runtime"
+	"time"
+)
+
+/*
+non-blocking and bounded versions of Send and Receive, so the channel can be used to shed load
+like `select { case ch <- conn: default: }` in the 10.11 worker pool.
+
+the permits have to add up after a send or receive gives up:
+- a send that gives up never got its capacity permit, so there's nothing to undo.
+- a receive releases a capacity permit before it waits (that's what lets a sender go ahead on an
+  unbuffered channel). when it gives up it takes that permit back. if it can't, a sender already
+  used it to push a message, so instead of giving up the receive returns that message. a receive
+  arriving after us can take that message first, but then the capacity permit it released is left
+  over for us to take back instead, see giveUpReceive.
+*/
+
+// TrySend sends message only if it can be done without blocking and reports whether it was sent.
+func (c *Channel[M]) TrySend(message M) bool {
+	if !c.capacitySemaphore.TryAcquire() {
+		return false
+	}
+	c.push(message)
+	return true
+}
+
+/*
+TryReceive receives a message only if one is buffered and reports whether it did.
+on an unbuffered channel it only succeeds if a sender has already pushed a message.
+*/
+func (c *Channel[M]) TryReceive() (M, bool) {
+	if !c.sizeSemaphore.TryAcquire() {
+		var zero M
+		return zero, false
+	}
+	v, ok := c.take()
+	if ok {
+		// the message is gone from the buffer, so there's space for one more
+		c.capacitySemaphore.Release()
+	}
+	return v, ok
+}
+
+/*
+SendContext blocks until message is sent or ctx is done, in which case ctx.Err() is returned
+and nothing was sent. like Send it panics if the channel is closed, and follows the Overflow policy.
+*/
+func (c *Channel[M]) SendContext(ctx context.Context, message M) error {
+	// the other policies never block, so there's nothing to wait for
+	if c.policy != Block {
+		return c.Send(message)
+	}
+	if err := c.capacitySemaphore.AcquireContext(ctx); err != nil {
+		return err
+	}
+	c.push(message)
+	return nil
+}
+
+// SendTimeout waits at most d to send message and reports whether it was sent.
+func (c *Channel[M]) SendTimeout(message M, d time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return c.SendContext(ctx, message) == nil
+}
+
+/*
+ReceiveContext blocks until a message is received or ctx is done, in which case ctx.Err()
+is returned. returns ErrClosed once the channel is closed and empty.
+*/
+func (c *Channel[M]) ReceiveContext(ctx context.Context) (M, error) {
+	var zero M
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	c.capacitySemaphore.Release()
+	if err := c.sizeSemaphore.AcquireContext(ctx); err != nil {
+		if c.giveUpReceive() {
+			return zero, err
+		}
+	}
+
+	v, ok := c.take()
+	if !ok {
+		return zero, ErrClosed
+	}
+	return v, nil
+}
+
+// ReceiveTimeout waits at most d for a message and reports whether one was received.
+func (c *Channel[M]) ReceiveTimeout(d time.Duration) (M, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	v, err := c.ReceiveContext(ctx)
+	return v, err == nil
+}
+
+/*
+called by a receive that gave up after releasing its capacity permit. reports true once the permit
+was taken back, or false once a size permit was acquired and the receive has to take a message instead.
+
+blocking on the size semaphore isn't enough: the message pushed with our permit can be taken by a
+receive that arrived after us, and if no more messages are sent we'd wait forever. the permits always
+add up though. every receive that released a capacity permit and hasn't acquired a size permit yet
+accounts for one capacity permit or buffered message (or a sender that's about to push one), and we're
+one of them. so one of the two shows up as soon as the sends already under way are done, and we
+just have to check both until it does.
+*/
+func (c *Channel[M]) giveUpReceive() bool {
+	for {
+		if c.reclaimCapacity() {
+			return true
+		}
+		if c.sizeSemaphore.TryAcquire() {
+			return false
+		}
+		runtime.Gosched()
+	}
+}
+
+/*
+takes back the capacity permit released by a receive that gave up and reports whether it could.
+once the channel is closed, the capacity permits are only used to wake up blocked senders so they
+can panic, so it's left alone and the receive can just give up.
+*/
+func (c *Channel[M]) reclaimCapacity() bool {
+	c.mutex.Lock()
+	closed := c.closed
+	c.mutex.Unlock()
+	return closed || c.capacitySemaphore.TryAcquire()
+}
+
+// Len returns the number of buffered messages.
+func (c *Channel[M]) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.buffer.Len()
+}
+
+// Cap returns the capacity the channel was created with.
+func (c *Channel[M]) Cap() int {
+	return c.capacity
+}
@@ -0,0 +1,135 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+/*
+a send or receive that gives up must leave the permits as they were. the only way to see them from
+outside is how many messages can be sent without blocking, which has to stay the capacity.
+*/
+func expectRoomFor(t *testing.T, c *Channel[int], capacity int) {
+	t.Helper()
+	for i := range capacity {
+		if !c.TrySend(i) {
+			t.Fatalf("capacity %d: only room for %d messages", capacity, i)
+		}
+	}
+	if c.TrySend(capacity) {
+		t.Fatalf("capacity %d: room for more messages than the capacity", capacity)
+	}
+	for i := range capacity {
+		if v, ok := c.TryReceive(); !ok || v != i {
+			t.Fatalf("capacity %d: expected %d, got %d %v", capacity, i, v, ok)
+		}
+	}
+	if _, ok := c.TryReceive(); ok {
+		t.Fatalf("capacity %d: received more messages than were sent", capacity)
+	}
+}
+
+func TestAbortedSendKeepsCapacity(t *testing.T) {
+	for _, capacity := range []int{0, 2} {
+		c := NewChannel[int](capacity)
+		for i := range capacity {
+			c.Send(i)
+		}
+		for range 3 {
+			if c.SendTimeout(-1, 5*time.Millisecond) {
+				t.Fatalf("capacity %d: SendTimeout succeeded on a full channel", capacity)
+			}
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := c.SendContext(ctx, -1); !errors.Is(err, context.Canceled) {
+			t.Fatalf("capacity %d: expected context.Canceled, got %v", capacity, err)
+		}
+
+		if c.Len() != capacity {
+			t.Fatalf("capacity %d: expected %d buffered, got %d", capacity, capacity, c.Len())
+		}
+		for i := range capacity {
+			if v, _ := c.Receive(); v != i {
+				t.Fatalf("capacity %d: expected %d, got %d", capacity, i, v)
+			}
+		}
+		expectRoomFor(t, c, capacity)
+	}
+}
+
+// a receive releases a capacity permit while it waits, so it has to take it back when it gives up
+func TestAbortedReceiveKeepsCapacity(t *testing.T) {
+	for _, capacity := range []int{0, 2} {
+		c := NewChannel[int](capacity)
+		for range 3 {
+			if _, ok := c.ReceiveTimeout(5 * time.Millisecond); ok {
+				t.Fatalf("capacity %d: ReceiveTimeout got a message from an empty channel", capacity)
+			}
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := c.ReceiveContext(ctx); !errors.Is(err, context.Canceled) {
+			t.Fatalf("capacity %d: expected context.Canceled, got %v", capacity, err)
+		}
+		expectRoomFor(t, c, capacity)
+	}
+}
+
+/*
+senders and receives keep giving up at random points while the others go ahead. a sender can use the
+capacity permit of a receive that's giving up, and a receive arriving after can take the message pushed
+with it. every call has to return, and the messages sent have to add up with the ones received.
+*/
+func TestAbortedCallsRacingEachOther(t *testing.T) {
+	const goroutines, calls = 4, 2000
+	c := NewChannel[int](0)
+
+	var sent, received atomic.Int64
+	wg := sync.WaitGroup{}
+	for range goroutines {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := range calls {
+				if c.SendTimeout(i, time.Duration(rand.Intn(50))*time.Microsecond) {
+					sent.Add(1)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range calls {
+				if _, ok := c.ReceiveTimeout(time.Duration(rand.Intn(50)) * time.Microsecond); ok {
+					received.Add(1)
+				}
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("a send or receive that gave up is still blocked")
+	}
+
+	// a message pushed with the permit of a receive that gave up is still buffered
+	leftOver := int64(c.Len())
+	if sent.Load() != received.Load()+leftOver {
+		t.Fatalf("sent %d messages, received %d with %d left over", sent.Load(), received.Load(), leftOver)
+	}
+	for range leftOver {
+		c.Receive()
+	}
+	expectRoomFor(t, c, 0)
+}
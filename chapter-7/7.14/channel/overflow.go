@@ -0,0 +1,80 @@
+package channel
+
+import "errors"
+
+/*
+Overflow is what Send does when the buffer is full.
+
+- Block waits until there's space, the same as a built-in chan. this is the default.
+- DropNewest throws away the message being sent.
+- DropOldest throws away the oldest buffered message to make space for the one being sent.
+- Error doesn't send the message and returns ErrFull.
+
+a telemetry stream like generateTemp in 8.3.1 would rather lose old readings than block the producer:
+
+	temps := channel.NewChannel[int](10, channel.WithOverflow(channel.DropOldest))
+
+an unbuffered channel never buffers anything, so it's full whenever no receiver is waiting. DropOldest
+has nothing older to throw away then and drops the message being sent, same as DropNewest.
+
+the policy applies to Send, SendContext and SendTimeout. TrySend already tells the caller the channel
+is full by returning false, so it's left as it is.
+*/
+type Overflow int
+
+const (
+	Block Overflow = iota
+	DropNewest
+	DropOldest
+	Error
+)
+
+// ErrFull is returned by Send when the buffer is full and the channel uses the Error policy.
+var ErrFull = errors.New("channel: send on full channel")
+
+// settings given to NewChannel, kept out of Channel so options don't need the message type
+type config struct {
+	overflow Overflow
+}
+
+type Option func(*config)
+
+// WithOverflow sets what Send does when the buffer is full, Block by default.
+func WithOverflow(policy Overflow) Option {
+	return func(c *config) {
+		c.overflow = policy
+	}
+}
+
+/*
+Dropped returns how many messages the overflow policy didn't let through: the messages thrown
+away by DropNewest or DropOldest, or the ones rejected with ErrFull by Error. always 0 with Block.
+*/
+func (c *Channel[M]) Dropped() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.dropped
+}
+
+/*
+called by a send that couldn't get a capacity permit without blocking, with a policy other than Block.
+the number of buffered messages stays the same either way, so the size permits still match the buffer.
+*/
+func (c *Channel[M]) overflow(message M) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		panic("send on closed channel")
+	}
+
+	c.dropped++
+	switch {
+	case c.policy == Error:
+		return ErrFull
+	case c.policy == DropOldest && c.buffer.Len() > 0:
+		c.buffer.Remove(c.buffer.Front())
+		c.buffer.PushBack(message)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// sends 1 to n without a receiver, none of them may block whatever the policy is
+func sendAll(t *testing.T, c *Channel[int], n int) []error {
+	t.Helper()
+	errs := make([]error, n)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range n {
+			errs[i] = c.Send(i + 1)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send blocked on a full channel")
+	}
+	return errs
+}
+
+func expectReceived(t *testing.T, c *Channel[int], want ...int) {
+	t.Helper()
+	for _, w := range want {
+		if v, ok := c.TryReceive(); !ok || v != w {
+			t.Fatalf("expected %d, got %d %v", w, v, ok)
+		}
+	}
+	if v, ok := c.TryReceive(); ok {
+		t.Fatalf("expected nothing else buffered, got %d", v)
+	}
+}
+
+func TestDropNewest(t *testing.T) {
+	c := NewChannel[int](2, WithOverflow(DropNewest))
+	for _, err := range sendAll(t, c, 5) {
+		if err != nil {
+			t.Fatalf("expected DropNewest to return nil, got %v", err)
+		}
+	}
+	if c.Dropped() != 3 {
+		t.Fatalf("expected 3 dropped, got %d", c.Dropped())
+	}
+	expectReceived(t, c, 1, 2)
+	expectRoomFor(t, c, 2)
+}
+
+func TestDropOldest(t *testing.T) {
+	c := NewChannel[int](2, WithOverflow(DropOldest))
+	for _, err := range sendAll(t, c, 5) {
+		if err != nil {
+			t.Fatalf("expected DropOldest to return nil, got %v", err)
+		}
+	}
+	if c.Dropped() != 3 {
+		t.Fatalf("expected 3 dropped, got %d", c.Dropped())
+	}
+	expectReceived(t, c, 4, 5)
+	expectRoomFor(t, c, 2)
+}
+
+func TestErrorPolicy(t *testing.T) {
+	c := NewChannel[int](2, WithOverflow(Error))
+	errs := sendAll(t, c, 3)
+	if errs[0] != nil || errs[1] != nil || !errors.Is(errs[2], ErrFull) {
+		t.Fatalf("expected only the third send to fail with ErrFull, got %v", errs)
+	}
+
+	// the bounded sends don't wait either
+	if err := c.SendContext(context.Background(), 4); !errors.Is(err, ErrFull) {
+		t.Fatalf("expected ErrFull from SendContext, got %v", err)
+	}
+	if c.SendTimeout(5, time.Hour) {
+		t.Fatal("SendTimeout succeeded on a full channel")
+	}
+	if c.Dropped() != 3 {
+		t.Fatalf("expected 3 rejected, got %d", c.Dropped())
+	}
+	expectReceived(t, c, 1, 2)
+	expectRoomFor(t, c, 2)
+}
+
+func TestBlockDropsNothing(t *testing.T) {
+	c := NewChannel[int](1)
+	c.Send(1)
+	if c.SendTimeout(2, 5*time.Millisecond) {
+		t.Fatal("SendTimeout succeeded on a full channel")
+	}
+	if c.Dropped() != 0 {
+		t.Fatalf("expected nothing dropped with Block, got %d", c.Dropped())
+	}
+}
+
+// with nothing buffered DropOldest has nothing older to throw away, so it drops the message sent
+func TestUnbufferedDropOldestDropsTheNewMessage(t *testing.T) {
+	c := NewChannel[int](0, WithOverflow(DropOldest))
+	sendAll(t, c, 3)
+	if c.Dropped() != 3 || c.Len() != 0 {
+		t.Fatalf("expected 3 dropped and nothing buffered, got %d dropped and %d buffered", c.Dropped(), c.Len())
+	}
+
+	// a waiting receiver still gets a message, once it's waiting sends stop being dropped
+	received := make(chan int)
+	go func() {
+		v, _ := c.Receive()
+		received <- v
+	}()
+	for sent := 1; ; sent++ {
+		c.Send(sent)
+		select {
+		case v := <-received:
+			// only the one message it got went through, every other send was dropped
+			if v < 1 || v > sent || c.Dropped() != 3+sent-1 {
+				t.Fatalf("receiver got %d after %d sends with %d dropped", v, sent, c.Dropped())
+			}
+			expectRoomFor(t, c, 0)
+			return
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
@@ -1,3 +1,13 @@
 module 7.14
 
 go 1.22.2
+
+require (
+	semstats v0.0.0
+	syncutil v0.0.0
+)
+
+replace (
+	semstats => ../../chapter-5/semstats
+	syncutil => ../../chapter-5/syncutil
+)
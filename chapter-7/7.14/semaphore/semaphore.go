@@ -1,26 +1,101 @@
 package semaphore
 
-import "sync"
+import (
+	"context"
+	"sync"
+	"time"
+
+	"semstats"
+	"syncutil"
+)
 
 type Semaphore struct {
 	permits int
 	cond    *sync.Cond
+	stats   *semstats.Stats
+}
+
+// Option configures optional behaviour of a Semaphore when it's created.
+type Option func(*Semaphore)
+
+// WithStats reports every acquire and release of the semaphore to stats.
+func WithStats(stats *semstats.Stats) Option {
+	return func(rw *Semaphore) {
+		rw.stats = stats
+	}
 }
 
-func NewSemaphore(n int) *Semaphore {
-	return &Semaphore{
+func NewSemaphore(n int, opts ...Option) *Semaphore {
+	rw := &Semaphore{
 		permits: n,
 		cond:    sync.NewCond(&sync.Mutex{}),
 	}
+	for _, opt := range opts {
+		opt(rw)
+	}
+	return rw
 }
 
 func (rw *Semaphore) Acquire() {
+	start := time.Now()
+	rw.cond.L.Lock()
+	for rw.permits <= 0 {
+		rw.cond.Wait()
+	}
+	rw.permits--
+	rw.cond.L.Unlock()
+
+	if rw.stats != nil {
+		rw.stats.Acquired(1, time.Since(start))
+	}
+}
+
+// TryAcquire acquires a permit only if one is available right now and reports whether it did.
+func (rw *Semaphore) TryAcquire() bool {
 	rw.cond.L.Lock()
+	if rw.permits <= 0 {
+		rw.cond.L.Unlock()
+		return false
+	}
+	rw.permits--
+	rw.cond.L.Unlock()
+
+	if rw.stats != nil {
+		rw.stats.Acquired(1, 0)
+	}
+	return true
+}
+
+/*
+AcquireContext blocks until a permit is acquired or ctx is done, in which case ctx.Err()
+is returned and no permit is taken. the waiting goroutines are woken up once ctx is done
+by syncutil.BroadcastOnDone.
+*/
+func (rw *Semaphore) AcquireContext(ctx context.Context) error {
+	start := time.Now()
+	rw.cond.L.Lock()
+
+	stop := syncutil.BroadcastOnDone(ctx, rw.cond)
+	defer stop()
+
 	for rw.permits <= 0 {
+		/*
+		   permits are checked before ctx, so if a Release() signalled us at the same
+		   time ctx got done we still take the permit instead of losing the signal.
+		*/
+		if err := ctx.Err(); err != nil {
+			rw.cond.L.Unlock()
+			return err
+		}
 		rw.cond.Wait()
 	}
 	rw.permits--
 	rw.cond.L.Unlock()
+
+	if rw.stats != nil {
+		rw.stats.Acquired(1, time.Since(start))
+	}
+	return nil
 }
 
 func (rw *Semaphore) Release() {
@@ -29,4 +104,8 @@ func (rw *Semaphore) Release() {
 
 	rw.cond.Signal()
 	rw.cond.L.Unlock()
+
+	if rw.stats != nil {
+		rw.stats.Released(1)
+	}
 }
@@ -0,0 +1,74 @@
+package channel
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	semchannel "7.14/channel"
+)
+
+/*
+compares the cond based Channel with its list and ring buffer storage, the semaphore based
+channel from 7.14 and a built-in chan. run with `go test -bench . -run '^$' ./channel`.
+
+every benchmark sends b.N messages through a channel with bufferSize slots, split between the
+producers, while the same number of consumers receive them until the channel is closed.
+*/
+
+const bufferSize = 64
+
+func BenchmarkChannels(b *testing.B) {
+	channels := []struct {
+		name       string
+		newChannel func() testChannel
+	}{
+		{"list", func() testChannel { return NewChannel[int](bufferSize) }},
+		{"ring", func() testChannel { return NewChannel[int](bufferSize, WithRingBuffer()) }},
+		{"semaphore", func() testChannel { return semchannel.NewChannel[int](bufferSize) }},
+		{"chan", func() testChannel { return make(builtinChan, bufferSize) }},
+	}
+
+	for _, goroutines := range []int{1, 4, 16} {
+		for _, ch := range channels {
+			b.Run(fmt.Sprintf("goroutines_%d/%s", goroutines, ch.name), func(b *testing.B) {
+				benchmarkChannel(b, ch.newChannel(), goroutines)
+			})
+		}
+	}
+}
+
+// goroutines producers and as many consumers pass b.N messages through ch
+func benchmarkChannel(b *testing.B, ch testChannel, goroutines int) {
+	b.ReportAllocs()
+
+	consumers := sync.WaitGroup{}
+	for range goroutines {
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			for _, ok := ch.Receive(); ok; _, ok = ch.Receive() {
+			}
+		}()
+	}
+
+	producers := sync.WaitGroup{}
+	for p := range goroutines {
+		// the first producers send one more message when b.N doesn't divide evenly
+		messages := b.N / goroutines
+		if p < b.N%goroutines {
+			messages++
+		}
+		producers.Add(1)
+		go func() {
+			defer producers.Done()
+			for i := range messages {
+				ch.Send(i)
+			}
+		}()
+	}
+
+	producers.Wait()
+	ch.Close()
+	consumers.Wait()
+}
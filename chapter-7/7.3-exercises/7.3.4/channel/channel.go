@@ -3,36 +3,101 @@ package channel
 import (
 	"container/list"
 	"sync"
+	"sync/atomic"
 )
 
-// channel implemented with Condition Variable
+/*
+channel implemented with Condition Variable
+
+Close() works the same way as closing a built-in chan:
+  - Send() on a closed channel panics, including senders that were blocked when it got closed.
+  - Receive() keeps returning the buffered messages after the channel is closed, and once
+    the buffer is empty returns the zero value and false straight away instead of blocking.
+    receivers that were blocked when it got closed are woken up and do the same.
+  - closing a channel that's already closed panics.
+
+this means a consumer can read until the producer is done, the same as ranging over a chan:
+
+	for msg, ok := ch.Receive(); ok; msg, ok = ch.Receive() {
+		...
+	}
+*/
 type Channel[M any] struct {
 	cond     sync.Cond
-	buffer   *list.List // standard library queue implementation
+	buffer   queue[M] // a list by default or a ring buffer, see queue.go
 	capacity int
+	size     int // the capacity the channel was created with, capacity also counts the waiting receivers
+	closed   bool
+	policy   Overflow // what Send does when the buffer is full, see overflow.go
+	dropped  int      // messages not let through by policy
+
+	// used by Select, see select.go. the selectors with a send case and a receive case are kept apart, see wakeSenders
+	id               uint64
+	sendSelectors    list.List
+	receiveSelectors list.List
 }
 
-func NewChannel[M any](capacity int) *Channel[M] {
+// ids given to channels so Select always locks them in the same order
+var nextId atomic.Uint64
+
+func NewChannel[M any](capacity int, opts ...Option) *Channel[M] {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	var buffer queue[M] = &listQueue[M]{}
+	if cfg.ring {
+		// an unbuffered channel still needs a slot to hand a message over to a waiting receiver
+		buffer = newRingBuffer[M](max(capacity, 1))
+	}
 	return &Channel[M]{
 		cond:     *sync.NewCond(&sync.Mutex{}),
-		buffer:   list.New(),
+		buffer:   buffer,
 		capacity: capacity,
+		size:     capacity,
+		policy:   cfg.overflow,
+		id:       nextId.Add(1),
 	}
 }
 
-func (c *Channel[M]) Send(message M) {
+/*
+Send sends message, blocking while the buffer is full unless the channel was created with
+a different Overflow policy. returns ErrFull if the buffer is full and the policy is Error.
+*/
+func (c *Channel[M]) Send(message M) error {
 	c.cond.L.Lock()
 
-	for c.buffer.Len() == c.capacity {
+	if c.policy != Block && c.full() && !c.closed {
+		err := c.overflow(message)
+		c.cond.L.Unlock()
+		return err
+	}
+
+	/*
+	   the buffer can hold more messages than capacity after a Select that was waiting to receive
+	   did a different case (see select.go), so this checks for >= and not just ==. otherwise a
+	   sender would never block again once that happened.
+	*/
+	for c.full() && !c.closed {
 		c.cond.Wait()
 	}
 
-	c.buffer.PushBack(message)
-	c.cond.Broadcast()
+	if c.closed {
+		c.cond.L.Unlock()
+		panic("send on closed channel")
+	}
+
+	c.buffer.push(message)
+	c.broadcast()
 	c.cond.L.Unlock()
+	return nil
 }
 
-func (c *Channel[M]) Receive() M {
+/*
+Receive returns the next message and true, or the zero value and false
+once the channel is closed and there are no buffered messages left.
+*/
+func (c *Channel[M]) Receive() (M, bool) {
 	c.cond.L.Lock()
 
 	/*
@@ -44,18 +109,79 @@ func (c *Channel[M]) Receive() M {
 	c.capacity++
 
 	/*
-	   broadcasting here so any blocked .Send() calls can attempt to get lock
+	   waking up the blocked .Send() calls so they can attempt to get lock
 	   and push message to buffer.
 	*/
-	c.cond.Broadcast()
+	c.wakeSenders()
 
-	for c.buffer.Len() == 0 {
+	for c.buffer.len() == 0 && !c.closed {
 		c.cond.Wait()
 	}
 
 	c.capacity--
-	v := c.buffer.Remove(c.buffer.Front()).(M)
+
+	// the channel got closed and everything that was buffered has already been received
+	if c.buffer.len() == 0 {
+		c.cond.L.Unlock()
+		var zero M
+		return zero, false
+	}
+
+	v := c.buffer.pop()
 	c.cond.L.Unlock()
 
-	return v
+	return v, true
+}
+
+/*
+Close marks the channel as closed so no more messages can be sent, and wakes up
+every blocked goroutine so receivers can return and senders can panic.
+*/
+func (c *Channel[M]) Close() {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	if c.closed {
+		panic("close of closed channel")
+	}
+	c.closed = true
+	c.broadcast()
+}
+
+/*
+reports whether a send has to wait, either because there are capacity messages buffered already
+or because the ring buffer has no free slot left. must be called with the cond lock held.
+*/
+func (c *Channel[M]) full() bool {
+	return c.buffer.len() >= c.capacity || c.buffer.full()
+}
+
+/*
+wakes up every goroutine blocked on the channel, both the ones in Send/Receive
+and the ones in Select. must be called with the cond lock held.
+*/
+func (c *Channel[M]) broadcast() {
+	c.cond.Broadcast()
+	signalAll(&c.sendSelectors)
+	signalAll(&c.receiveSelectors)
+}
+
+/*
+wakes up the goroutines that may be waiting to send, after a receiver increased capacity. the
+selectors that only wait to receive are left alone: more capacity doesn't make a receive ready,
+and waking them up would have them register again, increasing capacity again and waking up every
+other receiving selector, so idle Selects on the same channel would keep waking each other up.
+the cond is still broadcast since Send and Receive wait on the same cond, but a woken Receive
+just goes back to waiting. must be called with the cond lock held.
+*/
+func (c *Channel[M]) wakeSenders() {
+	c.cond.Broadcast()
+	signalAll(&c.sendSelectors)
+}
+
+// signals every selector in selectors, a list of *selector
+func signalAll(selectors *list.List) {
+	for elem := selectors.Front(); elem != nil; elem = elem.Next() {
+		elem.Value.(*selector).signal()
+	}
 }
@@ -0,0 +1,159 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"syncutil"
+)
+
+/*
+non-blocking and bounded versions of Send and Receive, so the channel can be used to shed load
+like `select { case ch <- conn: default: }` in the 10.11 worker pool.
+
+the bounded versions wait on the cond like Send and Receive, and syncutil.BroadcastOnDone wakes them
+up once ctx is done. a receive that gives up takes back the capacity it added for itself, the same as
+Receive does once it's done.
+*/
+
+// ErrClosed is returned by ReceiveContext once the channel is closed and there are no buffered messages left.
+var ErrClosed = errors.New("channel: receive from closed channel")
+
+/*
+TrySend sends message only if it can be done without blocking and reports whether it was sent.
+on an unbuffered channel it only succeeds if a receiver is waiting. like Send it panics if the
+channel is closed.
+*/
+func (c *Channel[M]) TrySend(message M) bool {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	if c.closed {
+		panic("send on closed channel")
+	}
+	if c.full() {
+		return false
+	}
+	c.buffer.push(message)
+	c.broadcast()
+	return true
+}
+
+/*
+TryReceive receives a message only if one is buffered and reports whether it did.
+on an unbuffered channel it only succeeds if a sender has already pushed a message.
+*/
+func (c *Channel[M]) TryReceive() (M, bool) {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	if c.buffer.len() == 0 {
+		var zero M
+		return zero, false
+	}
+	v := c.buffer.pop()
+	// there's space for one more message now, so let any blocked sender know
+	c.broadcast()
+	return v, true
+}
+
+/*
+SendContext blocks until message is sent or ctx is done, in which case ctx.Err() is returned
+and nothing was sent. like Send it panics if the channel is closed, and follows the Overflow policy.
+*/
+func (c *Channel[M]) SendContext(ctx context.Context, message M) error {
+	// the other policies never block, so there's nothing to wait for
+	if c.policy != Block {
+		return c.Send(message)
+	}
+
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	stop := syncutil.BroadcastOnDone(ctx, &c.cond)
+	defer stop()
+
+	for c.full() && !c.closed {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c.cond.Wait()
+	}
+
+	if c.closed {
+		panic("send on closed channel")
+	}
+
+	c.buffer.push(message)
+	c.broadcast()
+	return nil
+}
+
+// SendTimeout waits at most d to send message and reports whether it was sent.
+func (c *Channel[M]) SendTimeout(message M, d time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return c.SendContext(ctx, message) == nil
+}
+
+/*
+ReceiveContext blocks until a message is received or ctx is done, in which case ctx.Err()
+is returned. returns ErrClosed once the channel is closed and empty.
+
+a sender may have pushed a message because of the capacity this receive added, right before ctx
+got done. the buffer is checked before ctx so that message is still received instead of being left
+behind. if the sender comes after we gave up the capacity is already back to what it was, so it
+blocks like it would without us.
+*/
+func (c *Channel[M]) ReceiveContext(ctx context.Context) (M, error) {
+	var zero M
+
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+	stop := syncutil.BroadcastOnDone(ctx, &c.cond)
+	defer stop()
+
+	// same as Receive(), let a sender push a message even if the channel is unbuffered
+	c.capacity++
+	c.wakeSenders()
+
+	for c.buffer.len() == 0 && !c.closed {
+		if err := ctx.Err(); err != nil {
+			c.capacity--
+			return zero, err
+		}
+		c.cond.Wait()
+	}
+
+	c.capacity--
+
+	if c.buffer.len() == 0 {
+		return zero, ErrClosed
+	}
+	return c.buffer.pop(), nil
+}
+
+// ReceiveTimeout waits at most d for a message and reports whether one was received.
+func (c *Channel[M]) ReceiveTimeout(d time.Duration) (M, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	v, err := c.ReceiveContext(ctx)
+	return v, err == nil
+}
+
+// Len returns the number of buffered messages.
+func (c *Channel[M]) Len() int {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+	return c.buffer.len()
+}
+
+// Cap returns the capacity the channel was created with.
+func (c *Channel[M]) Cap() int {
+	return c.size
+}
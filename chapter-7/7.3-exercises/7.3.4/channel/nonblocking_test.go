@@ -0,0 +1,147 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+/*
+a send or receive that gives up must leave capacity as it was, or senders would later get through
+without a receiver (too much) or block with one waiting (too little). checked under the cond lock
+and then from outside, by how many messages can be sent without blocking.
+*/
+func expectRoomFor(t *testing.T, c *Channel[int], name string, capacity int) {
+	t.Helper()
+	c.cond.L.Lock()
+	got := c.capacity
+	c.cond.L.Unlock()
+	if got != capacity {
+		t.Fatalf("%s: expected capacity %d, got %d", name, capacity, got)
+	}
+
+	for i := range capacity {
+		if !c.TrySend(i) {
+			t.Fatalf("%s: only room for %d messages", name, i)
+		}
+	}
+	if c.TrySend(capacity) {
+		t.Fatalf("%s: room for more messages than the capacity", name)
+	}
+	for i := range capacity {
+		if v, ok := c.TryReceive(); !ok || v != i {
+			t.Fatalf("%s: expected %d, got %d %v", name, i, v, ok)
+		}
+	}
+}
+
+func TestAbortedSendKeepsCapacity(t *testing.T) {
+	for _, storage := range storages {
+		for _, capacity := range []int{0, 2} {
+			c := NewChannel[int](capacity, storage.opts...)
+			for i := range capacity {
+				c.Send(i)
+			}
+			for range 3 {
+				if c.SendTimeout(-1, 5*time.Millisecond) {
+					t.Fatalf("%s: SendTimeout succeeded on a full channel", storage.name)
+				}
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if err := c.SendContext(ctx, -1); !errors.Is(err, context.Canceled) {
+				t.Fatalf("%s: expected context.Canceled, got %v", storage.name, err)
+			}
+
+			if c.Len() != capacity {
+				t.Fatalf("%s: expected %d buffered, got %d", storage.name, capacity, c.Len())
+			}
+			for i := range capacity {
+				if v, _ := c.Receive(); v != i {
+					t.Fatalf("%s: expected %d, got %d", storage.name, i, v)
+				}
+			}
+			expectRoomFor(t, c, storage.name, capacity)
+		}
+	}
+}
+
+// a receive adds one to capacity while it waits, so it has to take it back when it gives up
+func TestAbortedReceiveKeepsCapacity(t *testing.T) {
+	for _, storage := range storages {
+		for _, capacity := range []int{0, 2} {
+			c := NewChannel[int](capacity, storage.opts...)
+			for range 3 {
+				if _, ok := c.ReceiveTimeout(5 * time.Millisecond); ok {
+					t.Fatalf("%s: ReceiveTimeout got a message from an empty channel", storage.name)
+				}
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if _, err := c.ReceiveContext(ctx); !errors.Is(err, context.Canceled) {
+				t.Fatalf("%s: expected context.Canceled, got %v", storage.name, err)
+			}
+			expectRoomFor(t, c, storage.name, capacity)
+		}
+	}
+}
+
+/*
+senders and receives keep giving up at random points while the others go ahead, so a sender can push
+a message with the capacity of a receive right as it gives up. every call has to return, the messages
+sent have to add up with the ones received, and capacity has to end up where it started.
+*/
+func TestAbortedCallsRacingEachOther(t *testing.T) {
+	const goroutines, calls = 4, 2000
+	for _, storage := range storages {
+		c := NewChannel[int](0, storage.opts...)
+
+		var sent, received atomic.Int64
+		wg := sync.WaitGroup{}
+		for range goroutines {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				for i := range calls {
+					if c.SendTimeout(i, time.Duration(rand.Intn(50))*time.Microsecond) {
+						sent.Add(1)
+					}
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for range calls {
+					if _, ok := c.ReceiveTimeout(time.Duration(rand.Intn(50)) * time.Microsecond); ok {
+						received.Add(1)
+					}
+				}
+			}()
+		}
+
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(30 * time.Second):
+			t.Fatalf("%s: a send or receive that gave up is still blocked", storage.name)
+		}
+
+		// a message pushed right as its receive gave up is still buffered
+		leftOver := int64(c.Len())
+		if sent.Load() != received.Load()+leftOver {
+			t.Fatalf("%s: sent %d messages, received %d with %d left over",
+				storage.name, sent.Load(), received.Load(), leftOver)
+		}
+		for range leftOver {
+			c.Receive()
+		}
+		expectRoomFor(t, c, storage.name, 0)
+	}
+}
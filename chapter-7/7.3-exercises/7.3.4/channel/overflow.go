@@ -0,0 +1,75 @@
+package channel
+
+import "errors"
+
+/*
+Overflow is what Send does when the buffer is full.
+
+- Block waits until there's space, the same as a built-in chan. this is the default.
+- DropNewest throws away the message being sent.
+- DropOldest throws away the oldest buffered message to make space for the one being sent.
+- Error doesn't send the message and returns ErrFull.
+
+a telemetry stream like generateTemp in 8.3.1 would rather lose old readings than block the producer:
+
+	temps := channel.NewChannel[int](10, channel.WithOverflow(channel.DropOldest))
+
+an unbuffered channel never buffers anything, so it's full whenever no receiver is waiting. DropOldest
+has nothing older to throw away then and drops the message being sent, same as DropNewest.
+
+the policy applies to Send, SendContext and SendTimeout. TrySend already tells the caller the channel
+is full by returning false and a SendCase in a Select isn't ready while it's full, so those are left as
+they are.
+*/
+type Overflow int
+
+const (
+	Block Overflow = iota
+	DropNewest
+	DropOldest
+	Error
+)
+
+// ErrFull is returned by Send when the buffer is full and the channel uses the Error policy.
+var ErrFull = errors.New("channel: send on full channel")
+
+// settings given to NewChannel, kept out of Channel so options don't need the message type
+type config struct {
+	overflow Overflow
+	ring     bool
+}
+
+type Option func(*config)
+
+// WithOverflow sets what Send does when the buffer is full, Block by default.
+func WithOverflow(policy Overflow) Option {
+	return func(c *config) {
+		c.overflow = policy
+	}
+}
+
+/*
+Dropped returns how many messages the overflow policy didn't let through: the messages thrown
+away by DropNewest or DropOldest, or the ones rejected with ErrFull by Error. always 0 with Block.
+*/
+func (c *Channel[M]) Dropped() int {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+	return c.dropped
+}
+
+/*
+called by a send that found the buffer full, with a policy other than Block. must be called with the
+cond lock held. the number of buffered messages stays the same either way, so nothing needs waking up.
+*/
+func (c *Channel[M]) overflow(message M) error {
+	c.dropped++
+	switch {
+	case c.policy == Error:
+		return ErrFull
+	case c.policy == DropOldest && c.buffer.len() > 0:
+		c.buffer.pop()
+		c.buffer.push(message)
+	}
+	return nil
+}
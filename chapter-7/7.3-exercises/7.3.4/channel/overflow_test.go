@@ -0,0 +1,158 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+/*
+every policy is run with both storages. the sends go around the ring buffer a few times, so
+DropOldest also has to pop and push across the end of the slice.
+*/
+
+// sends 1 to n without a receiver, none of them may block whatever the policy is
+func sendAll(t *testing.T, c *Channel[int], name string, n int) []error {
+	t.Helper()
+	errs := make([]error, n)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range n {
+			errs[i] = c.Send(i + 1)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s: Send blocked on a full channel", name)
+	}
+	return errs
+}
+
+func expectReceived(t *testing.T, c *Channel[int], name string, want ...int) {
+	t.Helper()
+	for _, w := range want {
+		if v, ok := c.TryReceive(); !ok || v != w {
+			t.Fatalf("%s: expected %d, got %d %v", name, w, v, ok)
+		}
+	}
+	if v, ok := c.TryReceive(); ok {
+		t.Fatalf("%s: expected nothing else buffered, got %d", name, v)
+	}
+}
+
+func TestDropNewest(t *testing.T) {
+	for _, storage := range storages {
+		c := NewChannel[int](3, append(storage.opts, WithOverflow(DropNewest))...)
+		for _, err := range sendAll(t, c, storage.name, 10) {
+			if err != nil {
+				t.Fatalf("%s: expected DropNewest to return nil, got %v", storage.name, err)
+			}
+		}
+		if c.Dropped() != 7 {
+			t.Fatalf("%s: expected 7 dropped, got %d", storage.name, c.Dropped())
+		}
+		expectReceived(t, c, storage.name, 1, 2, 3)
+		expectRoomFor(t, c, storage.name, 3)
+	}
+}
+
+func TestDropOldest(t *testing.T) {
+	for _, storage := range storages {
+		c := NewChannel[int](3, append(storage.opts, WithOverflow(DropOldest))...)
+		for _, err := range sendAll(t, c, storage.name, 10) {
+			if err != nil {
+				t.Fatalf("%s: expected DropOldest to return nil, got %v", storage.name, err)
+			}
+		}
+		if c.Dropped() != 7 {
+			t.Fatalf("%s: expected 7 dropped, got %d", storage.name, c.Dropped())
+		}
+		expectReceived(t, c, storage.name, 8, 9, 10)
+		expectRoomFor(t, c, storage.name, 3)
+	}
+}
+
+func TestErrorPolicy(t *testing.T) {
+	for _, storage := range storages {
+		c := NewChannel[int](2, append(storage.opts, WithOverflow(Error))...)
+		errs := sendAll(t, c, storage.name, 3)
+		if errs[0] != nil || errs[1] != nil || !errors.Is(errs[2], ErrFull) {
+			t.Fatalf("%s: expected only the third send to fail with ErrFull, got %v", storage.name, errs)
+		}
+
+		// the bounded sends don't wait either
+		if err := c.SendContext(context.Background(), 4); !errors.Is(err, ErrFull) {
+			t.Fatalf("%s: expected ErrFull from SendContext, got %v", storage.name, err)
+		}
+		if c.SendTimeout(5, time.Hour) {
+			t.Fatalf("%s: SendTimeout succeeded on a full channel", storage.name)
+		}
+		if c.Dropped() != 3 {
+			t.Fatalf("%s: expected 3 rejected, got %d", storage.name, c.Dropped())
+		}
+		expectReceived(t, c, storage.name, 1, 2)
+		expectRoomFor(t, c, storage.name, 2)
+	}
+}
+
+func TestBlockDropsNothing(t *testing.T) {
+	for _, storage := range storages {
+		c := NewChannel[int](1, storage.opts...)
+		c.Send(1)
+		if c.SendTimeout(2, 5*time.Millisecond) {
+			t.Fatalf("%s: SendTimeout succeeded on a full channel", storage.name)
+		}
+		if c.Dropped() != 0 {
+			t.Fatalf("%s: expected nothing dropped with Block, got %d", storage.name, c.Dropped())
+		}
+	}
+}
+
+// with nothing buffered DropOldest has nothing older to throw away, so it drops the message sent
+func TestUnbufferedDropOldestDropsTheNewMessage(t *testing.T) {
+	for _, storage := range storages {
+		c := NewChannel[int](0, append(storage.opts, WithOverflow(DropOldest))...)
+		sendAll(t, c, storage.name, 3)
+		if c.Dropped() != 3 || c.Len() != 0 {
+			t.Fatalf("%s: expected 3 dropped and nothing buffered, got %d dropped and %d buffered",
+				storage.name, c.Dropped(), c.Len())
+		}
+
+		// a waiting receiver adds capacity for its message, so that one isn't dropped
+		received := make(chan int)
+		go func() {
+			v, _ := c.Receive()
+			received <- v
+		}()
+		waitForCapacity(t, c, 1)
+		if err := c.Send(4); err != nil {
+			t.Fatalf("%s: expected nil, got %v", storage.name, err)
+		}
+		if v := <-received; v != 4 || c.Dropped() != 3 {
+			t.Fatalf("%s: expected the receiver to get 4 with 3 dropped, got %d with %d dropped",
+				storage.name, v, c.Dropped())
+		}
+		expectRoomFor(t, c, storage.name, 0)
+	}
+}
+
+// polls the capacity of c under its lock until it's n, a waiting receiver adds one to it
+func waitForCapacity(t *testing.T, c *Channel[int], n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.cond.L.Lock()
+		capacity := c.capacity
+		c.cond.L.Unlock()
+		if capacity == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected capacity %d, got %d", n, capacity)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
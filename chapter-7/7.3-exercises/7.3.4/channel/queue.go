@@ -0,0 +1,38 @@
+package channel
+
+import "container/list"
+
+/*
+the storage of the buffered messages. Channel only needs a FIFO queue, so the list and the ring
+buffer (see ring.go) are kept behind this and the rest of the channel doesn't care which one it got.
+*/
+type queue[M any] interface {
+	len() int
+	// reports whether the storage itself has no room left, whatever the capacity of the channel is
+	full() bool
+	// must only be called when the queue isn't full
+	push(message M)
+	// must only be called when the queue isn't empty
+	pop() M
+}
+
+// standard library queue implementation, it grows as needed so it's never full
+type listQueue[M any] struct {
+	list list.List
+}
+
+func (q *listQueue[M]) len() int {
+	return q.list.Len()
+}
+
+func (q *listQueue[M]) full() bool {
+	return false
+}
+
+func (q *listQueue[M]) push(message M) {
+	q.list.PushBack(message)
+}
+
+func (q *listQueue[M]) pop() M {
+	return q.list.Remove(q.list.Front()).(M)
+}
@@ -0,0 +1,254 @@
+package channel
+
+import (
+	"cmp"
+	"math/rand"
+	"slices"
+	"sync"
+)
+
+/*
+Select waits on several send and receive cases over our channels at once, like the built-in select.
+
+	var msg int
+	switch i, ok := channel.Select(messages.ReceiveCase(&msg), quit.ReceiveCase(nil)); i {
+	case 0:
+		if ok {
+			fmt.Println("Received:", msg)
+		}
+	case 1:
+		return
+	}
+
+how it works:
+1. all the channels of the cases are locked, always in the order of their ids so two
+   Selects over the same channels can't deadlock by locking them in opposite orders.
+2. the cases are checked starting from a random one, same as the built-in select, so one
+   case that's always ready doesn't starve the others. the first ready case is done and its
+   index returned. if none is ready and there's a default case, its index is returned instead.
+3. otherwise a selector is registered on every channel while all of them are still locked,
+   so no Send/Receive/Close can happen between checking the cases and registering. the
+   channels are unlocked and the selector waits until one of them signals it changed, then
+   everything is locked again, the selector removed and we go back to checking the cases.
+   a signal sent after registering is remembered in the selector, so no wake up is lost.
+
+receiving on an unbuffered channel works the same way as Receive(), by increasing capacity
+while waiting so a sender can push a message. if a different case is done first, that
+message stays buffered for the next receiver even though the channel is unbuffered. what's
+still guaranteed when that happens:
+  - messages are received in the order they were sent, the left over one included.
+  - the left over messages count against the capacity, so Send blocks until they've been
+    received. the buffer holds at most capacity messages plus one for each Select that was
+    waiting to receive when its other case was done, and never grows past that.
+  - what's lost is the hand-off: a Send on an unbuffered channel can return before any
+    receiver has taken its message.
+
+this is only done for this cond based channel. it needs to check and change the state of several
+channels while holding all their locks, which the semaphore based channel in 7.14 can't do since
+its goroutines block inside the semaphores.
+*/
+
+type caseKind int
+
+const (
+	sendCase caseKind = iota
+	receiveCase
+	defaultCase
+)
+
+/*
+a case of a Select. the functions are created by SendCase/ReceiveCase for the specific
+channel and message type, and are only called while holding lock.
+- ready reports whether the case can be done without blocking
+- commit does the send or receive and returns the ok of a receive
+- watch registers a selector on the channel and returns a function to remove it again
+*/
+type Case struct {
+	kind   caseKind
+	id     uint64
+	lock   sync.Locker
+	ready  func() bool
+	commit func() bool
+	watch  func(s *selector) func()
+}
+
+// DefaultCase makes Select return straight away with the index of this case if no other case is ready.
+func DefaultCase() Case {
+	return Case{kind: defaultCase}
+}
+
+// SendCase is a case that sends message on the channel.
+func (c *Channel[M]) SendCase(message M) Case {
+	return Case{
+		kind: sendCase,
+		id:   c.id,
+		lock: c.cond.L,
+		ready: func() bool {
+			return c.closed || !c.full()
+		},
+		commit: func() bool {
+			if c.closed {
+				panic("send on closed channel")
+			}
+			c.buffer.push(message)
+			c.broadcast()
+			return true
+		},
+		watch: func(s *selector) func() {
+			elem := c.sendSelectors.PushBack(s)
+			return func() { c.sendSelectors.Remove(elem) }
+		},
+	}
+}
+
+/*
+ReceiveCase is a case that receives a message from the channel and stores it in into,
+unless into is nil. Select returns false instead if the channel is closed and empty.
+*/
+func (c *Channel[M]) ReceiveCase(into *M) Case {
+	return Case{
+		kind: receiveCase,
+		id:   c.id,
+		lock: c.cond.L,
+		ready: func() bool {
+			return c.closed || c.buffer.len() > 0
+		},
+		commit: func() bool {
+			var v M
+			ok := c.buffer.len() > 0
+			if ok {
+				v = c.buffer.pop()
+				// wakes up senders waiting for space in the buffer
+				c.broadcast()
+			}
+			if into != nil {
+				*into = v
+			}
+			return ok
+		},
+		watch: func(s *selector) func() {
+			// same as Receive(), so a sender can push a message even on an unbuffered channel
+			c.capacity++
+			c.wakeSenders()
+			elem := c.receiveSelectors.PushBack(s)
+			return func() {
+				c.capacity--
+				c.receiveSelectors.Remove(elem)
+			}
+		},
+	}
+}
+
+/*
+Select blocks until one of the cases can be done, does it and returns its index together with
+the ok of a receive case (always true for a send case). with a DefaultCase it doesn't block.
+*/
+func Select(cases ...Case) (int, bool) {
+	locks := lockOrder(cases)
+	lockAll(locks)
+
+	defaultIndex := -1
+	for i, c := range cases {
+		if c.kind == defaultCase {
+			defaultIndex = i
+		}
+	}
+
+	for {
+		if len(cases) > 0 {
+			start := rand.Intn(len(cases))
+			for n := range len(cases) {
+				i := (start + n) % len(cases)
+				if cases[i].kind != defaultCase && cases[i].ready() {
+					return i, commit(cases[i], locks)
+				}
+			}
+		}
+
+		if defaultIndex >= 0 {
+			unlockAll(locks)
+			return defaultIndex, false
+		}
+
+		s := newSelector()
+		var unwatches []func()
+		for _, c := range cases {
+			unwatches = append(unwatches, c.watch(s))
+		}
+		unlockAll(locks)
+
+		s.wait()
+
+		lockAll(locks)
+		for _, unwatch := range unwatches {
+			unwatch()
+		}
+	}
+}
+
+/*
+a goroutine waiting in Select. signalled is set by a channel that changed,
+so a signal sent before the goroutine started waiting isn't lost.
+*/
+type selector struct {
+	signalled bool
+	cond      *sync.Cond
+}
+
+func newSelector() *selector {
+	return &selector{cond: sync.NewCond(&sync.Mutex{})}
+}
+
+func (s *selector) signal() {
+	s.cond.L.Lock()
+	s.signalled = true
+	s.cond.Signal()
+	s.cond.L.Unlock()
+}
+
+func (s *selector) wait() {
+	s.cond.L.Lock()
+	for !s.signalled {
+		s.cond.Wait()
+	}
+	s.cond.L.Unlock()
+}
+
+// does the case and unlocks the channels, even if the case panics because its channel is closed.
+func commit(c Case, locks []sync.Locker) bool {
+	defer unlockAll(locks)
+	return c.commit()
+}
+
+// returns the lock of every channel used by the cases once, ordered by the channel ids.
+func lockOrder(cases []Case) []sync.Locker {
+	var channelCases []Case
+	for _, c := range cases {
+		if c.kind != defaultCase {
+			channelCases = append(channelCases, c)
+		}
+	}
+	slices.SortFunc(channelCases, func(a, b Case) int {
+		return cmp.Compare(a.id, b.id)
+	})
+
+	var locks []sync.Locker
+	for i, c := range channelCases {
+		if i == 0 || c.id != channelCases[i-1].id {
+			locks = append(locks, c.lock)
+		}
+	}
+	return locks
+}
+
+func lockAll(locks []sync.Locker) {
+	for _, lock := range locks {
+		lock.Lock()
+	}
+}
+
+func unlockAll(locks []sync.Locker) {
+	for i := len(locks) - 1; i >= 0; i-- {
+		locks[i].Unlock()
+	}
+}
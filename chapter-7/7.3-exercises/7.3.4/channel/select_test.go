@@ -0,0 +1,268 @@
+package channel
+
+import (
+	"container/list"
+	"testing"
+	"time"
+)
+
+// polls the receive selectors registered on c under its lock until there are n of them
+func waitForReceiveSelectors(t *testing.T, c *Channel[int], n int) []*list.Element {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.cond.L.Lock()
+		var elems []*list.Element
+		for elem := c.receiveSelectors.Front(); elem != nil; elem = elem.Next() {
+			elems = append(elems, elem)
+		}
+		c.cond.L.Unlock()
+		if len(elems) == n {
+			return elems
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d receive selectors, got %d", n, len(elems))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+/*
+two idle Selects receiving on the same channel, the worker/quit pattern. each registration used to
+wake up the other Select, which registered again and woke up the first one, forever. once they're
+idle the same registrations must stay in place.
+*/
+func TestIdleReceiveSelectsDontWakeEachOther(t *testing.T) {
+	messages := NewChannel[int](0)
+	quit := NewChannel[int](0)
+
+	done := make(chan struct{})
+	for range 2 {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			var v int
+			for {
+				if i, ok := Select(messages.ReceiveCase(&v), quit.ReceiveCase(nil)); i == 1 || !ok {
+					return
+				}
+			}
+		}()
+	}
+
+	before := waitForReceiveSelectors(t, messages, 2)
+	time.Sleep(50 * time.Millisecond)
+	after := waitForReceiveSelectors(t, messages, 2)
+	for i := range before {
+		if before[i] != after[i] {
+			t.Fatal("idle Selects kept registering again")
+		}
+	}
+
+	quit.Close()
+	<-done
+	<-done
+}
+
+/*
+a Select waiting to receive on an unbuffered channel lets a sender push a message, but then does its
+other case, leaving the message buffered. Send used to only block while Len() == capacity, so from
+then on it never blocked again.
+*/
+func TestLeftOverMessageStillBlocksSenders(t *testing.T) {
+	for attempt := 0; ; attempt++ {
+		if attempt == 1000 {
+			t.Fatal("the Select never did its other case with a message pushed")
+		}
+		messages := NewChannel[int](0)
+		quit := NewChannel[int](0)
+
+		picked := make(chan int)
+		go func() {
+			var v int
+			i, _ := Select(messages.ReceiveCase(&v), quit.ReceiveCase(nil))
+			picked <- i
+		}()
+		waitForReceiveSelectors(t, messages, 1)
+
+		// the Select increased capacity, so this succeeds, then both cases are ready
+		if !messages.TrySend(1) {
+			t.Fatal("TrySend failed while a Select was waiting to receive")
+		}
+		quit.Close()
+		if <-picked != 1 {
+			continue
+		}
+
+		if messages.Len() != 1 || messages.Cap() != 0 {
+			t.Fatalf("expected 1 left over message, got Len %d Cap %d", messages.Len(), messages.Cap())
+		}
+		if messages.SendTimeout(2, 10*time.Millisecond) {
+			t.Fatal("SendTimeout succeeded past the left over message")
+		}
+		sent := make(chan struct{})
+		go func() {
+			messages.Send(3)
+			close(sent)
+		}()
+		select {
+		case <-sent:
+			t.Fatal("Send didn't block behind the left over message")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		// the left over message comes first, then the blocked sender gets its turn
+		if v, _ := messages.Receive(); v != 1 {
+			t.Fatalf("expected the left over message first, got %d", v)
+		}
+		if v, _ := messages.Receive(); v != 3 {
+			t.Fatalf("expected the blocked sender's message, got %d", v)
+		}
+		<-sent
+		return
+	}
+}
+
+// starts a goroutine running Select, the returned channel gets the index and ok it returned
+func selectAsync(cases ...Case) chan [2]any {
+	result := make(chan [2]any, 1)
+	go func() {
+		i, ok := Select(cases...)
+		result <- [2]any{i, ok}
+	}()
+	return result
+}
+
+func expectSelected(t *testing.T, result chan [2]any, index int, ok bool) {
+	t.Helper()
+	select {
+	case got := <-result:
+		if got[0] != index || got[1] != ok {
+			t.Fatalf("expected case %d with ok %v, got case %v with ok %v", index, ok, got[0], got[1])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Select still blocked")
+	}
+}
+
+func TestSendCase(t *testing.T) {
+	for _, storage := range storages {
+		// a buffered channel with room is ready straight away
+		buffered := NewChannel[int](1, storage.opts...)
+		if i, ok := Select(buffered.SendCase(5)); i != 0 || !ok {
+			t.Fatalf("%s: expected the send case, got %d %v", storage.name, i, ok)
+		}
+		if v, ok := buffered.TryReceive(); v != 5 || !ok {
+			t.Fatalf("%s: expected 5 to be sent, got %d %v", storage.name, v, ok)
+		}
+
+		// an unbuffered one has to wait for a receiver
+		unbuffered := NewChannel[int](0, storage.opts...)
+		result := selectAsync(unbuffered.SendCase(7))
+		if v, _ := unbuffered.Receive(); v != 7 {
+			t.Fatalf("%s: expected 7, got %d", storage.name, v)
+		}
+		expectSelected(t, result, 0, true)
+	}
+}
+
+func TestDefaultCase(t *testing.T) {
+	full := NewChannel[int](1)
+	full.Send(1)
+	empty := NewChannel[int](1)
+
+	// nothing is ready, so the default case is picked and nothing is sent or received
+	if i, ok := Select(full.SendCase(2), empty.ReceiveCase(nil), DefaultCase()); i != 2 || ok {
+		t.Fatalf("expected the default case, got %d %v", i, ok)
+	}
+	if full.Len() != 1 || empty.Len() != 0 {
+		t.Fatal("a case was done even though the default case was picked")
+	}
+
+	// a case that is ready always wins over the default case
+	var v int
+	for range 20 {
+		if i, ok := Select(DefaultCase(), full.ReceiveCase(&v)); i != 1 || !ok || v != 1 {
+			t.Fatalf("expected the ready receive case, got %d %v", i, ok)
+		}
+		full.Send(1)
+	}
+}
+
+func TestSelectWakesOnWhicheverChannelIsReady(t *testing.T) {
+	for _, storage := range storages {
+		channels := []*Channel[int]{
+			NewChannel[int](0, storage.opts...),
+			NewChannel[int](1, storage.opts...),
+			NewChannel[int](2, storage.opts...),
+		}
+		for ready, c := range channels {
+			var values [3]int
+			result := selectAsync(channels[0].ReceiveCase(&values[0]), channels[1].ReceiveCase(&values[1]),
+				channels[2].ReceiveCase(&values[2]))
+			for _, c := range channels {
+				waitForReceiveSelectors(t, c, 1)
+			}
+
+			c.Send(ready + 10)
+			expectSelected(t, result, ready, true)
+			if values[ready] != ready+10 {
+				t.Fatalf("%s: expected %d received on channel %d, got %d", storage.name, ready+10, ready, values[ready])
+			}
+		}
+	}
+}
+
+/*
+the send happens while the Select is still checking its cases or registering, so it's caught at any
+point of that. a signal sent before the Select waits has to be remembered, or it blocks forever.
+*/
+func TestSelectDoesNotLoseWakeUps(t *testing.T) {
+	for i := range 500 {
+		a, b := NewChannel[int](1), NewChannel[int](1)
+		result := selectAsync(a.ReceiveCase(nil), b.ReceiveCase(nil))
+		if i%2 == 0 {
+			a.Send(1)
+			expectSelected(t, result, 0, true)
+		} else {
+			b.Send(1)
+			expectSelected(t, result, 1, true)
+		}
+	}
+}
+
+func TestReceiveCaseOnClosedChannel(t *testing.T) {
+	for _, storage := range storages {
+		c := NewChannel[int](2, storage.opts...)
+		c.Send(1)
+		c.Close()
+
+		// the buffered message is still received, then the case reports the channel is closed
+		v := -1
+		if i, ok := Select(c.ReceiveCase(&v)); i != 0 || !ok || v != 1 {
+			t.Fatalf("%s: expected the buffered message, got %d %v %d", storage.name, i, ok, v)
+		}
+		if i, ok := Select(c.ReceiveCase(&v)); i != 0 || ok || v != 0 {
+			t.Fatalf("%s: expected ok=false and the zero value, got %d %v %d", storage.name, i, ok, v)
+		}
+
+		// a Select that was already waiting is woken up by Close
+		open := NewChannel[int](0, storage.opts...)
+		waiting := NewChannel[int](0, storage.opts...)
+		result := selectAsync(open.ReceiveCase(nil), waiting.ReceiveCase(nil))
+		waitForReceiveSelectors(t, waiting, 1)
+		waiting.Close()
+		expectSelected(t, result, 1, false)
+	}
+}
+
+func TestSendCaseOnClosedChannelPanics(t *testing.T) {
+	c := NewChannel[int](1)
+	c.Close()
+	if panicOf(func() { Select(c.SendCase(1)) }) != "panic" {
+		t.Fatal("send case on a closed channel didn't panic")
+	}
+	// the panic didn't leave the channel locked
+	if _, ok := c.Receive(); ok {
+		t.Fatal("expected the channel to be closed and empty")
+	}
+}
@@ -1,3 +1,15 @@
 module 7.3.4
 
 go 1.24.0
+
+require (
+	7.14 v0.0.0
+	semstats v0.0.0
+	syncutil v0.0.0
+)
+
+replace (
+	7.14 => ../../7.14
+	semstats => ../../../chapter-5/semstats
+	syncutil => ../../../chapter-5/syncutil
+)
@@ -19,15 +19,21 @@ func main() {
 		fmt.Println("Sending:", i)
 		intChan.Send(i)
 	}
-	intChan.Send(-1)
+	/*
+	   closing the channel instead of sending -1 to let the receiver know
+	   there's no more messages coming, the same as with a built-in chan.
+	*/
+	intChan.Close()
 	wg.Wait()
 }
 
 func receiver(messages *channel.Channel[int], wGroup *sync.WaitGroup) {
-	msg := 0
-	for msg != -1 {
+	for {
 		time.Sleep(1 * time.Second)
-		msg = messages.Receive()
+		msg, ok := messages.Receive()
+		if !ok {
+			break
+		}
 		fmt.Println("Received:", msg)
 	}
 	wGroup.Done()